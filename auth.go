@@ -0,0 +1,223 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// CredentialType describes how the client authenticates when fetching OAuth tokens for the Destination service.
+// Use the credential-type attribute in the service binding.
+type CredentialType string
+
+const (
+	// BindingSecretCredential authenticates with the client ID and client secret of the binding. This is the default
+	BindingSecretCredential CredentialType = "binding-secret"
+	// InstanceSecretCredential authenticates with the client ID and client secret of the service instance
+	InstanceSecretCredential CredentialType = "instance-secret"
+	// X509Credential authenticates with the client certificate of the binding, using mutual TLS
+	X509Credential CredentialType = "x509"
+)
+
+// tokenFetcher returns the function used for fetching OAuth tokens for the service, according to the credential type.
+// Tokens are fetched through base, unless the credential type requires a dedicated transport.
+func (c DestinationClientConfiguration) tokenFetcher(base http.RoundTripper) (func(context.Context) (*oauth2.Token, error), error) {
+	if c.TokenSource != nil {
+		return func(context.Context) (*oauth2.Token, error) {
+			return c.TokenSource.Token()
+		}, nil
+	}
+
+	conf := &clientcredentials.Config{
+		ClientID: c.ClientID,
+		Scopes:   []string{},
+	}
+	tokenClient := &http.Client{Transport: base}
+	switch c.CredentialType {
+	case "", BindingSecretCredential, InstanceSecretCredential:
+		conf.ClientSecret = c.ClientSecret
+		conf.TokenURL = c.TokenURL + "/oauth/token"
+	case X509Credential:
+		cert, err := c.clientCertificate()
+		if err != nil {
+			return nil, err
+		}
+		baseTransport, ok := base.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("credential type %q requires an *http.Transport, got %T", X509Credential, base)
+		}
+		transport := baseTransport.Clone()
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+		tokenClient = &http.Client{Transport: transport}
+
+		conf.TokenURL = c.CertURL + "/oauth/token"
+		if c.CertURL == "" {
+			conf.TokenURL = c.TokenURL + "/oauth/token"
+		}
+		conf.AuthStyle = oauth2.AuthStyleInParams
+	default:
+		return nil, fmt.Errorf("unsupported credential type %q", c.CredentialType)
+	}
+	return func(ctx context.Context) (*oauth2.Token, error) {
+		return conf.Token(context.WithValue(ctx, oauth2.HTTPClient, tokenClient))
+	}, nil
+}
+
+// baseTransport returns the transport for requests to the service and the token endpoint
+func (c DestinationClientConfiguration) baseTransport() (http.RoundTripper, error) {
+	custom := c.Transport
+	if custom == nil && c.HTTPClient != nil {
+		custom = c.HTTPClient.Transport
+	}
+	if custom != nil {
+		if c.Proxy != nil || len(c.RootCAs) > 0 {
+			return nil, errors.New("a custom Transport or HTTPClient cannot be combined with Proxy or RootCAs")
+		}
+		return custom, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.Proxy != nil {
+		transport.Proxy = http.ProxyURL(c.Proxy)
+	}
+	if len(c.RootCAs) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, cert := range c.RootCAs {
+			pool.AddCert(cert)
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	return transport, nil
+}
+
+// clientCertificate loads the X.509 client certificate and key, either from the PEM values or from the PEM files
+func (c DestinationClientConfiguration) clientCertificate() (tls.Certificate, error) {
+	certPEM, keyPEM := []byte(c.Certificate), []byte(c.Key)
+	if len(certPEM) == 0 && c.CertificateFile != "" {
+		var err error
+		if certPEM, err = os.ReadFile(c.CertificateFile); err != nil {
+			return tls.Certificate{}, fmt.Errorf("reading client certificate: %w", err)
+		}
+	}
+	if len(keyPEM) == 0 && c.KeyFile != "" {
+		var err error
+		if keyPEM, err = os.ReadFile(c.KeyFile); err != nil {
+			return tls.Certificate{}, fmt.Errorf("reading client certificate key: %w", err)
+		}
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("loading client certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// tokenTransport authorizes outgoing requests with an OAuth access token for the Destination service.
+// Unlike the transport returned by clientcredentials.Config.Client, the token is fetched with the context
+// of the request that needs it, so deadlines and cancellation apply to the token fetch as well.
+type tokenTransport struct {
+	fetch func(context.Context) (*oauth2.Token, error)
+	base  http.RoundTripper
+
+	mu      sync.Mutex
+	token   *oauth2.Token
+	pending *pendingToken
+}
+
+// pendingToken is a token fetch in progress, shared by the requests waiting for it
+type pendingToken struct {
+	done  chan struct{}
+	token *oauth2.Token
+	err   error
+	// canceled is set when the fetch failed because the context of the request running it was done
+	canceled bool
+}
+
+// Token returns a valid token, fetching a new one using ctx if the cached token has expired.
+// The token is fetched without holding the lock, and requests waiting for a fetch run by another request
+// give up when their own ctx is done. When the request running the fetch is canceled, the waiting requests fetch again.
+func (t *tokenTransport) Token(ctx context.Context) (*oauth2.Token, error) {
+	for {
+		t.mu.Lock()
+		if t.token.Valid() {
+			token := t.token
+			t.mu.Unlock()
+			return token, nil
+		}
+		call := t.pending
+		if call == nil {
+			break
+		}
+		t.mu.Unlock()
+		select {
+		case <-call.done:
+			if !call.canceled {
+				return call.token, call.err
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &pendingToken{done: make(chan struct{})}
+	t.pending = call
+	t.mu.Unlock()
+
+	call.token, call.err = t.fetch(ctx)
+	call.canceled = call.err != nil && ctx.Err() != nil
+
+	t.mu.Lock()
+	t.pending = nil
+	if call.err == nil {
+		t.token = call.token
+	}
+	t.mu.Unlock()
+	close(call.done)
+	return call.token, call.err
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Token(req.Context())
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	authorized := req.Clone(req.Context())
+	token.SetAuthHeader(authorized)
+	return t.base.RoundTrip(authorized)
+}
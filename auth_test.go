@@ -0,0 +1,185 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// newTestCertificate creates a self-signed certificate and returns it with its key, both PEM encoded
+func newTestCertificate(t *testing.T, commonName string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestX509TokenFetch(t *testing.T) {
+	certPEM, keyPEM := newTestCertificate(t, "destination-client")
+
+	tokenServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth/token" {
+			t.Errorf("unexpected token path %q", r.URL.Path)
+		}
+		if len(r.TLS.PeerCertificates) == 0 || r.TLS.PeerCertificates[0].Subject.CommonName != "destination-client" {
+			t.Error("expected the client certificate to be presented")
+		}
+		if got := r.FormValue("client_id"); got != "clientid" {
+			t.Errorf("unexpected client_id %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"x509-token","token_type":"bearer","expires_in":3600}`)
+	}))
+	tokenServer.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	tokenServer.StartTLS()
+	defer tokenServer.Close()
+
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer x509-token" {
+			t.Errorf("unexpected Authorization header %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[]`)
+	}))
+	defer service.Close()
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, []byte(certPEM), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, []byte(keyPEM), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	base := DestinationClientConfiguration{
+		ClientID:       "clientid",
+		CredentialType: X509Credential,
+		CertURL:        tokenServer.URL,
+		ServiceURL:     service.URL,
+		RootCAs:        []*x509.Certificate{tokenServer.Certificate()},
+	}
+	pemConf, fileConf := base, base
+	pemConf.Certificate, pemConf.Key = certPEM, keyPEM
+	fileConf.CertificateFile, fileConf.KeyFile = certFile, keyFile
+
+	for name, conf := range map[string]DestinationClientConfiguration{"pem": pemConf, "files": fileConf} {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.GetInstanceDestinationsCtx(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestX509InvalidCertificate(t *testing.T) {
+	_, err := NewClient(DestinationClientConfiguration{
+		ClientID:       "clientid",
+		CredentialType: X509Credential,
+		Certificate:    "not a certificate",
+		Key:            "not a key",
+		CertURL:        "https://example.com",
+	})
+	if err == nil {
+		t.Error("expected an error for an invalid certificate")
+	}
+}
+
+func TestParseBindingX509(t *testing.T) {
+	conf, err := ParseBinding(readFixture(t, "vcap_x509.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.CredentialType != X509Credential || conf.CertURL != "https://subdomain.authentication.cert.eu10.hana.ondemand.com" ||
+		conf.Certificate == "" || conf.Key == "" {
+		t.Errorf("unexpected configuration %#v", conf)
+	}
+}
+
+func TestTokenWaitHonorsContext(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	tokens := &tokenTransport{fetch: func(ctx context.Context) (*oauth2.Token, error) {
+		close(started)
+		<-release
+		return &oauth2.Token{AccessToken: "token", Expiry: time.Now().Add(time.Hour)}, nil
+	}}
+	fetched := make(chan error)
+	go func() {
+		_, err := tokens.Token(context.Background())
+		fetched <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	if _, err := tokens.Token(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded, got %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("the waiting request returned after %v", elapsed)
+	}
+	close(release)
+	if err := <-fetched; err != nil {
+		t.Fatal(err)
+	}
+	token, err := tokens.Token(context.Background())
+	if err != nil || token.AccessToken != "token" {
+		t.Errorf("expected the fetched token to be reused, got %v %v", token, err)
+	}
+}
@@ -0,0 +1,236 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// DestinationServiceLabel is the service label of Destination service bindings
+const DestinationServiceLabel = "destination"
+
+var (
+	// ErrBindingNotFound is returned when no service binding matches the requested selection
+	ErrBindingNotFound = errors.New("service binding not found")
+	// ErrAmbiguousBinding is returned when more than one service binding matches the requested selection
+	ErrAmbiguousBinding = errors.New("more than one service binding matches")
+)
+
+// BindingOption selects a specific service binding when several are available
+type BindingOption func(*bindingFilter)
+
+type bindingFilter struct {
+	name  string
+	label string
+	tag   string
+	// label required when the binding is not selected by name, label or tag
+	defaultLabel string
+}
+
+// WithBindingName selects the binding of the service instance with the given name
+func WithBindingName(name string) BindingOption {
+	return func(f *bindingFilter) {
+		f.name = name
+	}
+}
+
+// WithBindingLabel selects bindings of services with the given label. The default label is DestinationServiceLabel,
+// or ConnectivityServiceLabel when loading a Connectivity service binding
+func WithBindingLabel(label string) BindingOption {
+	return func(f *bindingFilter) {
+		f.label = label
+	}
+}
+
+// WithBindingTag selects bindings that carry the given tag
+func WithBindingTag(tag string) BindingOption {
+	return func(f *bindingFilter) {
+		f.tag = tag
+	}
+}
+
+func newBindingFilter(label string, opts []BindingOption) bindingFilter {
+	filter := bindingFilter{defaultLabel: label}
+	for _, opt := range opts {
+		opt(&filter)
+	}
+	return filter
+}
+
+// binding is a single service binding, as provided by VCAP_SERVICES or a service binding directory
+type binding struct {
+	Name         string                 `json:"name"`
+	InstanceName string                 `json:"instance_name"`
+	Label        string                 `json:"label"`
+	Tags         []string               `json:"tags"`
+	Credentials  map[string]interface{} `json:"credentials"`
+}
+
+func (f bindingFilter) matches(b binding) bool {
+	if f.name != "" && b.Name != f.name && b.InstanceName != f.name {
+		return false
+	}
+	if f.tag != "" && !slices.Contains(b.Tags, f.tag) {
+		return false
+	}
+	if f.label != "" {
+		return b.Label == f.label
+	}
+	// A binding selected by name or tag does not have to carry the default label, e.g. a user-provided service
+	return f.name != "" || f.tag != "" || b.Label == f.defaultLabel
+}
+
+func (f bindingFilter) String() string {
+	var parts []string
+	if f.name != "" {
+		parts = append(parts, fmt.Sprintf("name %q", f.name))
+	}
+	if f.label != "" {
+		parts = append(parts, fmt.Sprintf("label %q", f.label))
+	} else if f.name == "" && f.tag == "" {
+		parts = append(parts, fmt.Sprintf("label %q", f.defaultLabel))
+	}
+	if f.tag != "" {
+		parts = append(parts, fmt.Sprintf("tag %q", f.tag))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// selectBinding returns the single binding that matches the filter
+func (f bindingFilter) selectBinding(bindings []binding) (binding, error) {
+	var matched []binding
+	for _, b := range bindings {
+		if f.matches(b) {
+			matched = append(matched, b)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return binding{}, fmt.Errorf("%w: %s", ErrBindingNotFound, f)
+	case 1:
+		return matched[0], nil
+	}
+	names := make([]string, 0, len(matched))
+	for _, b := range matched {
+		names = append(names, b.Name)
+	}
+	slices.Sort(names)
+	return binding{}, fmt.Errorf("%w: %s: %s", ErrAmbiguousBinding, f, strings.Join(names, ", "))
+}
+
+// credential returns the named credential as a string
+func (b binding) credential(name string) string {
+	switch v := b.Credentials[name].(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// clientConfiguration builds a DestinationClientConfiguration from the binding credentials
+func (b binding) clientConfiguration() (DestinationClientConfiguration, error) {
+	conf := DestinationClientConfiguration{
+		ClientID:       b.credential("clientid"),
+		ClientSecret:   b.credential("clientsecret"),
+		TokenURL:       b.credential("url"),
+		ServiceURL:     b.credential("uri"),
+		CredentialType: CredentialType(b.credential("credential-type")),
+		Certificate:    b.credential("certificate"),
+		Key:            b.credential("key"),
+		CertURL:        b.credential("certurl"),
+	}
+	required := []struct{ name, value string }{
+		{"clientid", conf.ClientID},
+		{"uri", conf.ServiceURL},
+	}
+	if conf.CredentialType == X509Credential {
+		required = append(required, []struct{ name, value string }{
+			{"certificate", conf.Certificate},
+			{"key", conf.Key},
+			{"certurl", conf.CertURL},
+		}...)
+	} else {
+		required = append(required, []struct{ name, value string }{
+			{"clientsecret", conf.ClientSecret},
+			{"url", conf.TokenURL},
+		}...)
+	}
+	var missing []string
+	for _, c := range required {
+		if c.value == "" {
+			missing = append(missing, c.name)
+		}
+	}
+	if len(missing) > 0 {
+		return conf, fmt.Errorf("service binding %q is missing credentials: %s", b.Name, strings.Join(missing, ", "))
+	}
+	return conf, nil
+}
+
+// ParseBinding builds a DestinationClientConfiguration from the contents of the VCAP_SERVICES environment variable.
+// By default the single binding of a service labeled DestinationServiceLabel is used. When several bindings are present,
+// pass BindingOption values to select one of them.
+func ParseBinding(vcapServices []byte, opts ...BindingOption) (DestinationClientConfiguration, error) {
+	bindings, err := parseVCAPServices(vcapServices)
+	if err != nil {
+		return DestinationClientConfiguration{}, err
+	}
+	b, err := newBindingFilter(DestinationServiceLabel, opts).selectBinding(bindings)
+	if err != nil {
+		return DestinationClientConfiguration{}, err
+	}
+	return b.clientConfiguration()
+}
+
+// parseVCAPServices returns all the bindings in the contents of the VCAP_SERVICES environment variable
+func parseVCAPServices(vcapServices []byte) ([]binding, error) {
+	var services map[string][]binding
+	if err := json.Unmarshal(vcapServices, &services); err != nil {
+		return nil, fmt.Errorf("parsing VCAP_SERVICES: %w", err)
+	}
+	var bindings []binding
+	for label, instances := range services {
+		for _, b := range instances {
+			if b.Label == "" {
+				b.Label = label
+			}
+			bindings = append(bindings, b)
+		}
+	}
+	return bindings, nil
+}
+
+// NewClientFromEnv creates a new DestinationClient configured from the Destination service binding in the VCAP_SERVICES environment variable
+func NewClientFromEnv(opts ...BindingOption) (*DestinationClient, error) {
+	vcap, ok := os.LookupEnv("VCAP_SERVICES")
+	if !ok {
+		return nil, errors.New("VCAP_SERVICES is not set")
+	}
+	conf, err := ParseBinding([]byte(vcap), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conf)
+}
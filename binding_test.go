@@ -0,0 +1,100 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseBinding(t *testing.T) {
+	conf, err := ParseBinding(readFixture(t, "vcap_single.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DestinationClientConfiguration{
+		ClientID:     "sb-clone1!b1|destination-xsappname!b9",
+		ClientSecret: "secret1",
+		TokenURL:     "https://subdomain.authentication.eu10.hana.ondemand.com",
+		ServiceURL:   "https://destination-configuration.cfapps.eu10.hana.ondemand.com",
+	}
+	if !reflect.DeepEqual(conf, want) {
+		t.Errorf("got %#v, want %#v", conf, want)
+	}
+}
+
+func TestParseBindingSelection(t *testing.T) {
+	vcap := readFixture(t, "vcap_multiple.json")
+	tests := []struct {
+		name     string
+		opts     []BindingOption
+		clientID string
+		err      error
+	}{
+		{"ambiguous", nil, "", ErrAmbiguousBinding},
+		{"by name", []BindingOption{WithBindingName("dest-a")}, "client-a", nil},
+		{"by tag", []BindingOption{WithBindingTag("backend")}, "client-b", nil},
+		{"user-provided by name", []BindingOption{WithBindingName("custom-destination")}, "client-c", nil},
+		{"by label", []BindingOption{WithBindingLabel("user-provided")}, "client-c", nil},
+		{"name and label mismatch", []BindingOption{WithBindingName("dest-a"), WithBindingLabel("user-provided")}, "", ErrBindingNotFound},
+		{"unknown name", []BindingOption{WithBindingName("missing")}, "", ErrBindingNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := ParseBinding(vcap, tt.opts...)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if conf.ClientID != tt.clientID {
+				t.Errorf("got client ID %q, want %q", conf.ClientID, tt.clientID)
+			}
+		})
+	}
+}
+
+func TestParseBindingErrors(t *testing.T) {
+	_, err := ParseBinding(readFixture(t, "vcap_incomplete.json"))
+	if err == nil || !strings.Contains(err.Error(), "clientsecret, url") {
+		t.Errorf("expected missing credentials to be reported, got %v", err)
+	}
+
+	if _, err := ParseBinding([]byte(`{"xsuaa":[]}`)); !errors.Is(err, ErrBindingNotFound) {
+		t.Errorf("expected ErrBindingNotFound, got %v", err)
+	}
+	if _, err := ParseBinding([]byte(`not json`)); err == nil {
+		t.Error("expected an error for malformed VCAP_SERVICES")
+	}
+}
+
+func TestNewClientFromEnv(t *testing.T) {
+	t.Setenv("VCAP_SERVICES", string(readFixture(t, "vcap_single.json")))
+	if _, err := NewClientFromEnv(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores the results of Find operations. Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the cached result for key, if present
+	Get(key string) (DestinationLookupResult, bool)
+	// Set caches the result for key
+	Set(key string, result DestinationLookupResult)
+}
+
+// findCacheKey builds the cache key of a Find operation. The key is hashed, so user tokens are not kept in memory as map keys.
+func findCacheKey(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryCache is an in-memory Cache that keeps results for a fixed time, or until shortly before their authentication
+// tokens expire if that is sooner
+type MemoryCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+}
+
+type memoryCacheEntry struct {
+	result  DestinationLookupResult
+	expires time.Time
+}
+
+// NewMemoryCache creates a MemoryCache that keeps results for ttl
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{
+		ttl:     ttl,
+		entries: map[string]memoryCacheEntry{},
+	}
+}
+
+// Get implements Cache
+func (c *MemoryCache) Get(key string) (DestinationLookupResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return DestinationLookupResult{}, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return DestinationLookupResult{}, false
+	}
+	return entry.result, true
+}
+
+// Set implements Cache
+func (c *MemoryCache) Set(key string, result DestinationLookupResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	expires := now.Add(c.ttl)
+	if tokenExpiry := result.ExpiresAt(); !tokenExpiry.IsZero() && tokenExpiry.Add(-tokenExpirySkew).Before(expires) {
+		expires = tokenExpiry.Add(-tokenExpirySkew)
+	}
+	if !now.Before(expires) {
+		return
+	}
+	c.entries[key] = memoryCacheEntry{
+		result:  result,
+		expires: expires,
+	}
+}
@@ -18,21 +18,28 @@ package gosapcpdestinationclient
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
+	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/go-resty/resty/v2"
-	"golang.org/x/oauth2/clientcredentials"
+	"golang.org/x/oauth2"
 )
 
 // DestinationClient provides the client object for accessing destinations in the SAP Cloud Platform Cloud Foundry environment.
 type DestinationClient struct {
 	restyClient *resty.Client
+	cache       Cache
+	validate    bool
 }
 
 // DestinationFinder provides a Find method for discovering destinations on any level.
 type DestinationFinder interface {
-	Find(name string) (DestinationLookupResult, error)
+	Find(name string, userToken string) (DestinationLookupResult, error)
+	FindCtx(ctx context.Context, name string, userToken string) (DestinationLookupResult, error)
+	FindWithOptions(ctx context.Context, name string, opts FindOptions) (DestinationLookupResult, error)
 }
 
 // SubaccountDestinationManager provides an interface for methods that manage destinations on the Subaccount level
@@ -42,6 +49,13 @@ type SubaccountDestinationManager interface {
 	UpdateSubaccountDestination(dest Destination) (AffectedRecords, error)
 	GetSubaccountDestination(name string) (Destination, error)
 	DeleteSubaccountDestination(name string) (AffectedRecords, error)
+
+	GetSubaccountDestinationsCtx(ctx context.Context) ([]Destination, error)
+	ListSubaccountDestinations(ctx context.Context, opts ListOptions) (Page[Destination], error)
+	CreateSubaccountDestinationCtx(ctx context.Context, newDestination Destination) error
+	UpdateSubaccountDestinationCtx(ctx context.Context, dest Destination) (AffectedRecords, error)
+	GetSubaccountDestinationCtx(ctx context.Context, name string) (Destination, error)
+	DeleteSubaccountDestinationCtx(ctx context.Context, name string) (AffectedRecords, error)
 }
 
 // SubaccountCertificateManager provides an interface for methods that manage certificates on the Subaccount level
@@ -50,6 +64,12 @@ type SubaccountCertificateManager interface {
 	CreateSubaccountCertificate(cert Certificate) error
 	GetSubaccountCertificate(name string) (Certificate, error)
 	DeleteSubaccountCertificate(name string) (AffectedRecords, error)
+
+	GetSubaccountCertificatesCtx(ctx context.Context) ([]Certificate, error)
+	ListSubaccountCertificates(ctx context.Context, opts ListOptions) (Page[Certificate], error)
+	CreateSubaccountCertificateCtx(ctx context.Context, cert Certificate) error
+	GetSubaccountCertificateCtx(ctx context.Context, name string) (Certificate, error)
+	DeleteSubaccountCertificateCtx(ctx context.Context, name string) (AffectedRecords, error)
 }
 
 // InstanceDestinationManager provides an interface for methods that manage destinations on the Instance level
@@ -59,6 +79,13 @@ type InstanceDestinationManager interface {
 	UpdateInstanceDestination(dest Destination) (AffectedRecords, error)
 	GetInstanceDestination(name string) (Destination, error)
 	DeleteInstanceDestination(name string) (AffectedRecords, error)
+
+	GetInstanceDestinationsCtx(ctx context.Context) ([]Destination, error)
+	ListInstanceDestinations(ctx context.Context, opts ListOptions) (Page[Destination], error)
+	CreateInstanceDestinationCtx(ctx context.Context, newDestination Destination) error
+	UpdateInstanceDestinationCtx(ctx context.Context, dest Destination) (AffectedRecords, error)
+	GetInstanceDestinationCtx(ctx context.Context, name string) (Destination, error)
+	DeleteInstanceDestinationCtx(ctx context.Context, name string) (AffectedRecords, error)
 }
 
 // InstanceCertificateManager provides an interface for methods that manage certificates on the Instance level
@@ -67,7 +94,70 @@ type InstanceCertificateManager interface {
 	CreateInstanceCertificate(cert Certificate) error
 	GetInstanceCertificate(name string) (Certificate, error)
 	DeleteInstanceCertificate(name string) (AffectedRecords, error)
-}
+
+	GetInstanceCertificatesCtx(ctx context.Context) ([]Certificate, error)
+	ListInstanceCertificates(ctx context.Context, opts ListOptions) (Page[Certificate], error)
+	CreateInstanceCertificateCtx(ctx context.Context, cert Certificate) error
+	GetInstanceCertificateCtx(ctx context.Context, name string) (Certificate, error)
+	DeleteInstanceCertificateCtx(ctx context.Context, name string) (AffectedRecords, error)
+}
+
+// SubaccountFragmentManager provides an interface for methods that manage destination fragments on the Subaccount level
+type SubaccountFragmentManager interface {
+	GetSubaccountFragments() ([]Fragment, error)
+	CreateSubaccountFragment(fragment Fragment) error
+	UpdateSubaccountFragment(fragment Fragment) (AffectedRecords, error)
+	GetSubaccountFragment(name string) (Fragment, error)
+	DeleteSubaccountFragment(name string) (AffectedRecords, error)
+
+	GetSubaccountFragmentsCtx(ctx context.Context) ([]Fragment, error)
+	CreateSubaccountFragmentCtx(ctx context.Context, fragment Fragment) error
+	UpdateSubaccountFragmentCtx(ctx context.Context, fragment Fragment) (AffectedRecords, error)
+	GetSubaccountFragmentCtx(ctx context.Context, name string) (Fragment, error)
+	DeleteSubaccountFragmentCtx(ctx context.Context, name string) (AffectedRecords, error)
+}
+
+// InstanceFragmentManager provides an interface for methods that manage destination fragments on the Instance level
+type InstanceFragmentManager interface {
+	GetInstanceFragments() ([]Fragment, error)
+	CreateInstanceFragment(fragment Fragment) error
+	UpdateInstanceFragment(fragment Fragment) (AffectedRecords, error)
+	GetInstanceFragment(name string) (Fragment, error)
+	DeleteInstanceFragment(name string) (AffectedRecords, error)
+
+	GetInstanceFragmentsCtx(ctx context.Context) ([]Fragment, error)
+	CreateInstanceFragmentCtx(ctx context.Context, fragment Fragment) error
+	UpdateInstanceFragmentCtx(ctx context.Context, fragment Fragment) (AffectedRecords, error)
+	GetInstanceFragmentCtx(ctx context.Context, name string) (Fragment, error)
+	DeleteInstanceFragmentCtx(ctx context.Context, name string) (AffectedRecords, error)
+}
+
+// DestinationService combines all the operations provided by the Destination service
+type DestinationService interface {
+	DestinationFinder
+	SubaccountDestinationManager
+	SubaccountCertificateManager
+	SubaccountFragmentManager
+	InstanceDestinationManager
+	InstanceCertificateManager
+	InstanceFragmentManager
+
+	Destinations(scope Scope) ScopedManager[Destination]
+	Certificates(scope Scope) ScopedCollection[Certificate]
+	Fragments(scope Scope) ScopedManager[Fragment]
+}
+
+// DestinationClient implements all the published interfaces
+var (
+	_ DestinationFinder            = (*DestinationClient)(nil)
+	_ SubaccountDestinationManager = (*DestinationClient)(nil)
+	_ SubaccountCertificateManager = (*DestinationClient)(nil)
+	_ InstanceDestinationManager   = (*DestinationClient)(nil)
+	_ InstanceCertificateManager   = (*DestinationClient)(nil)
+	_ SubaccountFragmentManager    = (*DestinationClient)(nil)
+	_ InstanceFragmentManager      = (*DestinationClient)(nil)
+	_ DestinationService           = (*DestinationClient)(nil)
+)
 
 // DestinationClientConfiguration contains the values required for configuring a new Destination client
 type DestinationClientConfiguration struct {
@@ -77,30 +167,127 @@ type DestinationClientConfiguration struct {
 	ClientSecret string
 	// TokenURL for authentication purposes. Use the url attribute in the service binding
 	TokenURL string
+	// CredentialType selects how tokens are fetched. Use the credential-type attribute in the service binding. Defaults to BindingSecretCredential
+	CredentialType CredentialType
+	// Certificate is the PEM encoded client certificate chain for X509Credential. Use the certificate attribute in the service binding
+	Certificate string
+	// Key is the PEM encoded private key for X509Credential. Use the key attribute in the service binding
+	Key string
+	// CertificateFile is the path of a PEM file with the client certificate chain, used for X509Credential when Certificate is empty
+	CertificateFile string
+	// KeyFile is the path of a PEM file with the private key, used for X509Credential when Key is empty
+	KeyFile string
+	// CertURL is the token endpoint for X509Credential. Use the certurl attribute in the service binding. Defaults to TokenURL
+	CertURL string
 	// ServiceURL for accessing the service RESTful endpoint. Use the uri attribute in the service binding
 	ServiceURL string
-}
-
-// NewClient creates a new DestinationClient object configured according to the provided DestinationClientConfiguration object
-func NewClient(clientConf DestinationClientConfiguration) (*DestinationClient, error) {
-	conf := &clientcredentials.Config{
-		ClientID:     clientConf.ClientID,
-		ClientSecret: clientConf.ClientSecret,
-		TokenURL:     clientConf.TokenURL + "/oauth/token",
-		Scopes:       []string{},
+	// RetryPolicy for failed requests. Requests are not retried if this is nil
+	RetryPolicy *RetryPolicy
+	// HTTPClient used as the basis for requests to the service. The client is copied, and its Transport is wrapped to add
+	// the OAuth access token. A new client with a 60 second timeout is used if this is nil
+	HTTPClient *http.Client
+	// Transport for requests to the service and the token endpoint. Takes precedence over the Transport of HTTPClient
+	Transport http.RoundTripper
+	// TokenSource provides the OAuth access tokens for the service. If set, the credentials in this configuration are not used
+	TokenSource oauth2.TokenSource
+	// BasePath of the service RESTful API, relative to ServiceURL. Defaults to DefaultBasePath
+	BasePath string
+	// Proxy is the URL of an HTTP proxy for the default transport
+	Proxy *url.URL
+	// RootCAs are trusted by the default transport in addition to the system certificate pool
+	RootCAs []*x509.Certificate
+}
+
+// DefaultBasePath is the path of the Destination service RESTful API
+const DefaultBasePath = "/destination-configuration/v1"
+
+// defaultTimeout is the timeout of requests to the service, unless the provided HTTPClient specifies one
+const defaultTimeout = 60 * time.Second
+
+// NewClient creates a new DestinationClient object configured according to the provided DestinationClientConfiguration object.
+// The configuration is validated, and an error wrapping ErrInvalidConfiguration is returned if required values are missing or malformed.
+func NewClient(clientConf DestinationClientConfiguration, opts ...Option) (*DestinationClient, error) {
+	var options clientOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.transport != nil {
+		clientConf.Transport = options.transport
+	}
+	if options.retryPolicy != nil {
+		clientConf.RetryPolicy = options.retryPolicy
+	}
+	if err := clientConf.validate(); err != nil {
+		return nil, err
+	}
+
+	base, err := clientConf.baseTransport()
+	if err != nil {
+		return nil, err
+	}
+	fetch, err := clientConf.tokenFetcher(base)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Timeout: defaultTimeout,
+	}
+	if clientConf.HTTPClient != nil {
+		*client = *clientConf.HTTPClient
+		if client.Timeout == 0 {
+			client.Timeout = defaultTimeout
+		}
+	}
+	if options.timeout != 0 {
+		client.Timeout = options.timeout
+	}
+	client.Transport = &tokenTransport{
+		fetch: fetch,
+		base:  base,
+	}
+	if options.metrics != nil || options.tracer != nil {
+		client.Transport = &instrumentedTransport{
+			base:    client.Transport,
+			metrics: options.metrics,
+			tracer:  options.tracer,
+		}
+	}
+	basePath := clientConf.BasePath
+	if basePath == "" {
+		basePath = DefaultBasePath
 	}
-	client := conf.Client(context.Background())
 
 	restyClient := resty.NewWithClient(client).
-		SetHostURL(clientConf.ServiceURL+"/destination-configuration/v1").
-		SetHeader("Accept", "application/json").
-		SetTimeout(60 * time.Second)
+		SetBaseURL(clientConf.ServiceURL+basePath).
+		SetHeader("Accept", "application/json")
+	if options.userAgent != "" {
+		restyClient.SetHeader("User-Agent", options.userAgent)
+	}
+	if options.logger != nil {
+		restyClient.SetLogger(options.logger)
+	}
+	if options.metrics != nil || options.tracer != nil {
+		restyClient.OnBeforeRequest(setOperation)
+	}
+	if clientConf.RetryPolicy != nil {
+		clientConf.RetryPolicy.apply(restyClient)
+	}
 
 	return &DestinationClient{
 		restyClient: restyClient,
+		cache:       options.cache,
+		validate:    options.validate,
 	}, nil
 }
 
+// checkDestination validates a destination before it is created or updated, if enabled with WithValidation
+func (d *DestinationClient) checkDestination(dest Destination) error {
+	if !d.validate {
+		return nil
+	}
+	return validationErrors(Validate(dest)).err(dest.Name)
+}
+
 /****************************   Find a destination **********************************/
 
 // Find a destination by name on all levels and return the first match.
@@ -108,27 +295,49 @@ func NewClient(clientConf DestinationClientConfiguration) (*DestinationClient, e
 // If userToken is not empty, it is passed as the value of the `X-user-token` header. This enables token-exchange flows via the Find operation. If a token-exchange
 // is not required, pass an empty string as the userToken value.
 func (d *DestinationClient) Find(name string, userToken string) (DestinationLookupResult, error) {
+	return d.FindCtx(context.Background(), name, userToken)
+}
+
+// FindCtx is like Find, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) FindCtx(ctx context.Context, name string, userToken string) (DestinationLookupResult, error) {
+	return d.FindWithOptions(ctx, name, FindOptions{UserToken: userToken})
+}
+
+// FindWithOptions finds a destination by name on all levels, like Find, passing the values in opts to the service.
+func (d *DestinationClient) FindWithOptions(ctx context.Context, name string, opts FindOptions) (DestinationLookupResult, error) {
 
 	var retval DestinationLookupResult
 	var errResponse ErrorMessage
 
-	request := d.restyClient.R().
+	cacheKey := opts.cacheKey(name)
+	if d.cache != nil {
+		if cached, ok := d.cache.Get(cacheKey); ok {
+			return cached, nil
+		}
+	}
+	lookupTime := time.Now()
+
+	response, err := d.restyClient.R().
+		SetContext(ctx).
 		SetResult(&retval).
 		SetError(&errResponse).
 		SetPathParams(map[string]string{
 			"name": name,
-		})
-	if userToken != "" {
-		request.SetHeader("X-user-token", userToken)
-	}
-	response, err := request.Get("/destinations/{name}")
+		}).
+		SetHeaders(opts.headers()).
+		SetQueryParams(opts.queryParams()).
+		Get("/destinations/{name}")
 
 	if err != nil {
 		return retval, err
 	}
 	if response.StatusCode() != 200 {
-		errResponse.statusCode = response.StatusCode()
-		return retval, errResponse
+		return retval, newErrorMessage(response, errResponse)
+	}
+	retval.setExpiry(lookupTime)
+	// Token failures are usually temporary, and have no expiry that would limit how long they are cached
+	if d.cache != nil && (opts.SkipTokenRetrieval || retval.TokenError() == nil) {
+		d.cache.Set(cacheKey, retval)
 	}
 	return retval, nil
 }
@@ -137,408 +346,292 @@ func (d *DestinationClient) Find(name string, userToken string) (DestinationLook
 
 // GetSubaccountDestinations returns a list of destinations posted on subaccount level. If none is found, an empty array is returned. Subaccount is determined by the passed OAuth access token.
 func (d *DestinationClient) GetSubaccountDestinations() ([]Destination, error) {
+	return d.GetSubaccountDestinationsCtx(context.Background())
+}
 
-	var retval = make([]Destination, 0)
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetResult(&retval).
-		SetError(&errResponse).
-		Get("/subaccountDestinations")
-
-	if err != nil {
-		return retval, err
-	}
-	if response.StatusCode() != 200 {
-		errResponse.statusCode = response.StatusCode()
-		return retval, errResponse
-	}
-	return retval, nil
+// GetSubaccountDestinationsCtx is like GetSubaccountDestinations, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) GetSubaccountDestinationsCtx(ctx context.Context) ([]Destination, error) {
+	return d.Destinations(SubaccountScope).List(ctx)
 }
 
 // CreateSubaccountDestination creates a new destination on subaccount level. Subaccount is determined by the passed OAuth access token.
 func (d *DestinationClient) CreateSubaccountDestination(newDestination Destination) error {
+	return d.CreateSubaccountDestinationCtx(context.Background(), newDestination)
+}
 
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetBody(newDestination).
-		SetError(&errResponse).
-		Post("/subaccountDestinations")
-
-	if err != nil {
-		return err
-	}
-	if response.StatusCode() != 201 {
-		errResponse.statusCode = response.StatusCode()
-		return errResponse
-	}
-	return nil
+// CreateSubaccountDestinationCtx is like CreateSubaccountDestination, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) CreateSubaccountDestinationCtx(ctx context.Context, newDestination Destination) error {
+	return d.Destinations(SubaccountScope).Create(ctx, newDestination)
 }
 
 // UpdateSubaccountDestination updates (overwrites) an existing destination with a new destination, posted on subaccount level. Subaccount is determined by the passed OAuth access token
 func (d *DestinationClient) UpdateSubaccountDestination(dest Destination) (AffectedRecords, error) {
+	return d.UpdateSubaccountDestinationCtx(context.Background(), dest)
+}
 
-	var retval AffectedRecords
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetBody(dest).
-		SetResult(&retval).
-		SetError(&errResponse).
-		Put("/subaccountDestinations")
-
-	if err != nil {
-		return retval, err
-	}
-	if response.StatusCode() != 200 {
-		errResponse.statusCode = response.StatusCode()
-		return retval, errResponse
-	}
-	return retval, nil
+// UpdateSubaccountDestinationCtx is like UpdateSubaccountDestination, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) UpdateSubaccountDestinationCtx(ctx context.Context, dest Destination) (AffectedRecords, error) {
+	return d.Destinations(SubaccountScope).Update(ctx, dest)
 }
 
 // GetSubaccountDestination retrieves a named destination posted on subaccount level. Subaccount is determined by the passed OAuth access token.
 func (d *DestinationClient) GetSubaccountDestination(name string) (Destination, error) {
+	return d.GetSubaccountDestinationCtx(context.Background(), name)
+}
 
-	var retval Destination
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetResult(&retval).
-		SetError(&errResponse).
-		SetPathParams(map[string]string{
-			"name": name,
-		}).
-		Get("/subaccountDestinations/{name}")
-
-	if err != nil {
-		return retval, err
-	}
-	if response.StatusCode() != 200 {
-		errResponse.statusCode = response.StatusCode()
-		return retval, errResponse
-	}
-	return retval, nil
+// GetSubaccountDestinationCtx is like GetSubaccountDestination, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) GetSubaccountDestinationCtx(ctx context.Context, name string) (Destination, error) {
+	return d.Destinations(SubaccountScope).Get(ctx, name)
 }
 
 // DeleteSubaccountDestination deletes a destination posted on subaccount level. Subaccount is determined by the passed OAuth access token.
 func (d *DestinationClient) DeleteSubaccountDestination(name string) (AffectedRecords, error) {
+	return d.DeleteSubaccountDestinationCtx(context.Background(), name)
+}
 
-	var retval AffectedRecords
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetResult(&retval).
-		SetError(&errResponse).
-		SetPathParams(map[string]string{
-			"name": name,
-		}).
-		Delete("/subaccountDestinations/{name}")
-
-	if err != nil {
-		return retval, err
-	}
-	if response.StatusCode() != 200 {
-		errResponse.statusCode = response.StatusCode()
-		return retval, errResponse
-	}
-	return retval, nil
+// DeleteSubaccountDestinationCtx is like DeleteSubaccountDestination, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) DeleteSubaccountDestinationCtx(ctx context.Context, name string) (AffectedRecords, error) {
+	return d.Destinations(SubaccountScope).Delete(ctx, name)
 }
 
 /**************************** Subaccount Certificates **********************************/
 
 // GetSubaccountCertificates retrieves all certificates posted on the subaccount level. In none are found, an empty array is returned. The Subaccount is determined based on the passed OAuth access token
 func (d *DestinationClient) GetSubaccountCertificates() ([]Certificate, error) {
+	return d.GetSubaccountCertificatesCtx(context.Background())
+}
 
-	var retval = make([]Certificate, 0)
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetResult(&retval).
-		SetError(&errResponse).
-		Get("/subaccountCertificates")
-
-	if err != nil {
-		return retval, err
-	}
-	if response.StatusCode() != 200 {
-		errResponse.statusCode = response.StatusCode()
-		return retval, errResponse
-	}
-	return retval, nil
+// GetSubaccountCertificatesCtx is like GetSubaccountCertificates, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) GetSubaccountCertificatesCtx(ctx context.Context) ([]Certificate, error) {
+	return d.Certificates(SubaccountScope).List(ctx)
 }
 
 // CreateSubaccountCertificate creates a new certificate on the subaccount level. The Subaccount is determined by the passed OAuth access token
 func (d *DestinationClient) CreateSubaccountCertificate(cert Certificate) error {
+	return d.CreateSubaccountCertificateCtx(context.Background(), cert)
+}
 
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetBody(cert).
-		SetError(&errResponse).
-		Post("/subaccountCertificates")
-
-	if err != nil {
-		return err
-	}
-	if response.StatusCode() != 201 {
-		errResponse.statusCode = response.StatusCode()
-		return errResponse
-	}
-	return nil
+// CreateSubaccountCertificateCtx is like CreateSubaccountCertificate, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) CreateSubaccountCertificateCtx(ctx context.Context, cert Certificate) error {
+	return d.Certificates(SubaccountScope).Create(ctx, cert)
 }
 
 // GetSubaccountCertificate retrieves a named certificate posted on the subaccount level. The Subaccount is determined by the passed OAuth access token
 func (d *DestinationClient) GetSubaccountCertificate(name string) (Certificate, error) {
+	return d.GetSubaccountCertificateCtx(context.Background(), name)
+}
 
-	var retval Certificate
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetResult(&retval).
-		SetError(&errResponse).
-		SetPathParams(map[string]string{
-			"name": name,
-		}).
-		Get("/subaccountCertificate/{name}")
-
-	if err != nil {
-		return retval, err
-	}
-	if response.StatusCode() != 200 {
-		errResponse.statusCode = response.StatusCode()
-		return retval, errResponse
-	}
-	return retval, nil
+// GetSubaccountCertificateCtx is like GetSubaccountCertificate, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) GetSubaccountCertificateCtx(ctx context.Context, name string) (Certificate, error) {
+	return d.Certificates(SubaccountScope).Get(ctx, name)
 }
 
 // DeleteSubaccountCertificate deletes a certificate posted on the subaccount level. The Subaccount is determined by the passed OAuth access token
 func (d *DestinationClient) DeleteSubaccountCertificate(name string) (AffectedRecords, error) {
+	return d.DeleteSubaccountCertificateCtx(context.Background(), name)
+}
 
-	var retval AffectedRecords
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetResult(&retval).
-		SetError(&errResponse).
-		SetPathParams(map[string]string{
-			"name": name,
-		}).
-		Delete("/subaccountCertificate/{name}")
-
-	if err != nil {
-		return retval, err
-	}
-	if response.StatusCode() != 200 {
-		errResponse.statusCode = response.StatusCode()
-		return retval, errResponse
-	}
-	return retval, nil
+// DeleteSubaccountCertificateCtx is like DeleteSubaccountCertificate, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) DeleteSubaccountCertificateCtx(ctx context.Context, name string) (AffectedRecords, error) {
+	return d.Certificates(SubaccountScope).Delete(ctx, name)
 }
 
 /**************************** Destinations on an instance level **********************************/
 
 // GetInstanceDestinations retrieves all destinations on the service instance level. If none are found, an empty list is returned. Service instance and subaccount are determined the passed OAuth access token
 func (d *DestinationClient) GetInstanceDestinations() ([]Destination, error) {
+	return d.GetInstanceDestinationsCtx(context.Background())
+}
 
-	var retval = make([]Destination, 0)
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetResult(&retval).
-		SetError(&errResponse).
-		Get("/instanceDestinations")
-
-	if err != nil {
-		return retval, err
-	}
-	if response.StatusCode() != 200 {
-		errResponse.statusCode = response.StatusCode()
-		return retval, errResponse
-	}
-	return retval, nil
+// GetInstanceDestinationsCtx is like GetInstanceDestinations, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) GetInstanceDestinationsCtx(ctx context.Context) ([]Destination, error) {
+	return d.Destinations(InstanceScope).List(ctx)
 }
 
 // CreateInstanceDestination creates a new destination on the service instance level. The service instance and subaccount are determined by the passed OAuth access token
 func (d *DestinationClient) CreateInstanceDestination(newDestination Destination) error {
+	return d.CreateInstanceDestinationCtx(context.Background(), newDestination)
+}
 
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetBody(newDestination).
-		SetError(&errResponse).
-		Post("/instanceDestinations")
-
-	if err != nil {
-		return err
-	}
-	if response.StatusCode() != 201 {
-		errResponse.statusCode = response.StatusCode()
-		return errResponse
-	}
-	return nil
+// CreateInstanceDestinationCtx is like CreateInstanceDestination, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) CreateInstanceDestinationCtx(ctx context.Context, newDestination Destination) error {
+	return d.Destinations(InstanceScope).Create(ctx, newDestination)
 }
 
 // UpdateInstanceDestination updates (overwrites) an existing destination with the passed destination. The service instance and subaccount are determined by the passed OAuth access token
 func (d *DestinationClient) UpdateInstanceDestination(dest Destination) (AffectedRecords, error) {
+	return d.UpdateInstanceDestinationCtx(context.Background(), dest)
+}
 
-	var retval AffectedRecords
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetBody(dest).
-		SetResult(&retval).
-		SetError(&errResponse).
-		Put("/instanceDestinations")
-
-	if err != nil {
-		return retval, err
-	}
-	if response.StatusCode() != 200 {
-		errResponse.statusCode = response.StatusCode()
-		return retval, errResponse
-	}
-	return retval, nil
+// UpdateInstanceDestinationCtx is like UpdateInstanceDestination, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) UpdateInstanceDestinationCtx(ctx context.Context, dest Destination) (AffectedRecords, error) {
+	return d.Destinations(InstanceScope).Update(ctx, dest)
 }
 
 // GetInstanceDestination retrieves a destination posted on the service instance level. The service instance and subaccount are determined by the passed OAuth access token
 func (d *DestinationClient) GetInstanceDestination(name string) (Destination, error) {
+	return d.GetInstanceDestinationCtx(context.Background(), name)
+}
 
-	var retval Destination
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetResult(&retval).
-		SetError(&errResponse).
-		SetPathParams(map[string]string{
-			"name": name,
-		}).
-		Get("/instanceDestinations/{name}")
-
-	if err != nil {
-		return retval, err
-	}
-	if response.StatusCode() != 200 {
-		errResponse.statusCode = response.StatusCode()
-		return retval, errResponse
-	}
-	return retval, nil
+// GetInstanceDestinationCtx is like GetInstanceDestination, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) GetInstanceDestinationCtx(ctx context.Context, name string) (Destination, error) {
+	return d.Destinations(InstanceScope).Get(ctx, name)
 }
 
 // DeleteInstanceDestination deletes a destination posted on the service instance level. The service instance and subaccount are determined by the passed OAuth access token
 func (d *DestinationClient) DeleteInstanceDestination(name string) (AffectedRecords, error) {
+	return d.DeleteInstanceDestinationCtx(context.Background(), name)
+}
 
-	var retval AffectedRecords
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetResult(&retval).
-		SetError(&errResponse).
-		SetPathParams(map[string]string{
-			"name": name,
-		}).
-		Delete("/instanceDestinations/{name}")
-
-	if err != nil {
-		return retval, err
-	}
-	if response.StatusCode() != 200 {
-		errResponse.statusCode = response.StatusCode()
-		return retval, errResponse
-	}
-	return retval, nil
+// DeleteInstanceDestinationCtx is like DeleteInstanceDestination, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) DeleteInstanceDestinationCtx(ctx context.Context, name string) (AffectedRecords, error) {
+	return d.Destinations(InstanceScope).Delete(ctx, name)
 }
 
 /**************************** Instance Certificates **********************************/
 
 // GetInstanceCertificates retrieves all certificates posted on the service instance level. If none are found, an empty list is returned. The service instance and subaccount are determined by the passed OAuth access token
 func (d *DestinationClient) GetInstanceCertificates() ([]Certificate, error) {
+	return d.GetInstanceCertificatesCtx(context.Background())
+}
 
-	var retval = make([]Certificate, 0)
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetResult(&retval).
-		SetError(&errResponse).
-		Get("/instanceCertificates")
-
-	if err != nil {
-		return retval, err
-	}
-	if response.StatusCode() != 200 {
-		errResponse.statusCode = response.StatusCode()
-		return retval, errResponse
-	}
-	return retval, nil
+// GetInstanceCertificatesCtx is like GetInstanceCertificates, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) GetInstanceCertificatesCtx(ctx context.Context) ([]Certificate, error) {
+	return d.Certificates(InstanceScope).List(ctx)
 }
 
 // CreateInstanceCertificate creates a new certificate on the service instance level. The service instance and subaccount are determined by the passed OAuth access token
 func (d *DestinationClient) CreateInstanceCertificate(cert Certificate) error {
+	return d.CreateInstanceCertificateCtx(context.Background(), cert)
+}
 
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetBody(cert).
-		SetError(&errResponse).
-		Post("/instanceCertificates")
-
-	if err != nil {
-		return err
-	}
-	if response.StatusCode() != 201 {
-		errResponse.statusCode = response.StatusCode()
-		return errResponse
-	}
-	return nil
+// CreateInstanceCertificateCtx is like CreateInstanceCertificate, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) CreateInstanceCertificateCtx(ctx context.Context, cert Certificate) error {
+	return d.Certificates(InstanceScope).Create(ctx, cert)
 }
 
 // GetInstanceCertificate retrieves a certificate posted on the service instance level. The service instance and subaccount are determined by the passed OAuth access token
 func (d *DestinationClient) GetInstanceCertificate(name string) (Certificate, error) {
+	return d.GetInstanceCertificateCtx(context.Background(), name)
+}
 
-	var retval Certificate
-	var errResponse ErrorMessage
-
-	response, err := d.restyClient.R().
-		SetResult(&retval).
-		SetError(&errResponse).
-		SetPathParams(map[string]string{
-			"name": name,
-		}).
-		Get("/instanceCertificate/{name}")
-
-	if err != nil {
-		return retval, err
-	}
-	if response.StatusCode() != 200 {
-		errResponse.statusCode = response.StatusCode()
-		return retval, errResponse
-	}
-	return retval, nil
+// GetInstanceCertificateCtx is like GetInstanceCertificate, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) GetInstanceCertificateCtx(ctx context.Context, name string) (Certificate, error) {
+	return d.Certificates(InstanceScope).Get(ctx, name)
 }
 
 // DeleteInstanceCertificate deletes a certificate posted on the service instance level. The service instance and subaccount are determined by the passed OAuth access token
 func (d *DestinationClient) DeleteInstanceCertificate(name string) (AffectedRecords, error) {
+	return d.DeleteInstanceCertificateCtx(context.Background(), name)
+}
 
-	var retval AffectedRecords
-	var errResponse ErrorMessage
+// DeleteInstanceCertificateCtx is like DeleteInstanceCertificate, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) DeleteInstanceCertificateCtx(ctx context.Context, name string) (AffectedRecords, error) {
+	return d.Certificates(InstanceScope).Delete(ctx, name)
+}
 
-	response, err := d.restyClient.R().
-		SetResult(&retval).
-		SetError(&errResponse).
-		SetPathParams(map[string]string{
-			"name": name,
-		}).
-		Delete("/instanceCertificate/{name}")
+/**************************** Fragments on a subaccount level **********************************/
 
-	if err != nil {
-		return retval, err
-	}
-	if response.StatusCode() != 200 {
-		errResponse.statusCode = response.StatusCode()
-		return retval, errResponse
-	}
-	return retval, nil
+// GetSubaccountFragments retrieves all destination fragments posted on the subaccount level. If none are found, an empty list is returned. The Subaccount is determined by the passed OAuth access token
+func (d *DestinationClient) GetSubaccountFragments() ([]Fragment, error) {
+	return d.GetSubaccountFragmentsCtx(context.Background())
+}
+
+// GetSubaccountFragmentsCtx is like GetSubaccountFragments, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) GetSubaccountFragmentsCtx(ctx context.Context) ([]Fragment, error) {
+	return d.Fragments(SubaccountScope).List(ctx)
+}
+
+// CreateSubaccountFragment creates a new destination fragment on the subaccount level. The Subaccount is determined by the passed OAuth access token
+func (d *DestinationClient) CreateSubaccountFragment(fragment Fragment) error {
+	return d.CreateSubaccountFragmentCtx(context.Background(), fragment)
+}
+
+// CreateSubaccountFragmentCtx is like CreateSubaccountFragment, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) CreateSubaccountFragmentCtx(ctx context.Context, fragment Fragment) error {
+	return d.Fragments(SubaccountScope).Create(ctx, fragment)
+}
+
+// UpdateSubaccountFragment updates (overwrites) an existing destination fragment with the passed fragment, posted on the subaccount level. The Subaccount is determined by the passed OAuth access token
+func (d *DestinationClient) UpdateSubaccountFragment(fragment Fragment) (AffectedRecords, error) {
+	return d.UpdateSubaccountFragmentCtx(context.Background(), fragment)
+}
+
+// UpdateSubaccountFragmentCtx is like UpdateSubaccountFragment, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) UpdateSubaccountFragmentCtx(ctx context.Context, fragment Fragment) (AffectedRecords, error) {
+	return d.Fragments(SubaccountScope).Update(ctx, fragment)
+}
+
+// GetSubaccountFragment retrieves a named destination fragment posted on the subaccount level. The Subaccount is determined by the passed OAuth access token
+func (d *DestinationClient) GetSubaccountFragment(name string) (Fragment, error) {
+	return d.GetSubaccountFragmentCtx(context.Background(), name)
+}
+
+// GetSubaccountFragmentCtx is like GetSubaccountFragment, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) GetSubaccountFragmentCtx(ctx context.Context, name string) (Fragment, error) {
+	return d.Fragments(SubaccountScope).Get(ctx, name)
+}
+
+// DeleteSubaccountFragment deletes a destination fragment posted on the subaccount level. The Subaccount is determined by the passed OAuth access token
+func (d *DestinationClient) DeleteSubaccountFragment(name string) (AffectedRecords, error) {
+	return d.DeleteSubaccountFragmentCtx(context.Background(), name)
+}
+
+// DeleteSubaccountFragmentCtx is like DeleteSubaccountFragment, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) DeleteSubaccountFragmentCtx(ctx context.Context, name string) (AffectedRecords, error) {
+	return d.Fragments(SubaccountScope).Delete(ctx, name)
+}
+
+/**************************** Fragments on an instance level **********************************/
+
+// GetInstanceFragments retrieves all destination fragments posted on the service instance level. If none are found, an empty list is returned. The service instance and subaccount are determined by the passed OAuth access token
+func (d *DestinationClient) GetInstanceFragments() ([]Fragment, error) {
+	return d.GetInstanceFragmentsCtx(context.Background())
+}
+
+// GetInstanceFragmentsCtx is like GetInstanceFragments, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) GetInstanceFragmentsCtx(ctx context.Context) ([]Fragment, error) {
+	return d.Fragments(InstanceScope).List(ctx)
+}
+
+// CreateInstanceFragment creates a new destination fragment on the service instance level. The service instance and subaccount are determined by the passed OAuth access token
+func (d *DestinationClient) CreateInstanceFragment(fragment Fragment) error {
+	return d.CreateInstanceFragmentCtx(context.Background(), fragment)
+}
+
+// CreateInstanceFragmentCtx is like CreateInstanceFragment, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) CreateInstanceFragmentCtx(ctx context.Context, fragment Fragment) error {
+	return d.Fragments(InstanceScope).Create(ctx, fragment)
+}
+
+// UpdateInstanceFragment updates (overwrites) an existing destination fragment with the passed fragment, posted on the service instance level. The service instance and subaccount are determined by the passed OAuth access token
+func (d *DestinationClient) UpdateInstanceFragment(fragment Fragment) (AffectedRecords, error) {
+	return d.UpdateInstanceFragmentCtx(context.Background(), fragment)
+}
+
+// UpdateInstanceFragmentCtx is like UpdateInstanceFragment, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) UpdateInstanceFragmentCtx(ctx context.Context, fragment Fragment) (AffectedRecords, error) {
+	return d.Fragments(InstanceScope).Update(ctx, fragment)
+}
+
+// GetInstanceFragment retrieves a named destination fragment posted on the service instance level. The service instance and subaccount are determined by the passed OAuth access token
+func (d *DestinationClient) GetInstanceFragment(name string) (Fragment, error) {
+	return d.GetInstanceFragmentCtx(context.Background(), name)
+}
+
+// GetInstanceFragmentCtx is like GetInstanceFragment, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) GetInstanceFragmentCtx(ctx context.Context, name string) (Fragment, error) {
+	return d.Fragments(InstanceScope).Get(ctx, name)
+}
+
+// DeleteInstanceFragment deletes a destination fragment posted on the service instance level. The service instance and subaccount are determined by the passed OAuth access token
+func (d *DestinationClient) DeleteInstanceFragment(name string) (AffectedRecords, error) {
+	return d.DeleteInstanceFragmentCtx(context.Background(), name)
+}
+
+// DeleteInstanceFragmentCtx is like DeleteInstanceFragment, but uses ctx for the token fetch and the service request.
+func (d *DestinationClient) DeleteInstanceFragmentCtx(ctx context.Context, name string) (AffectedRecords, error) {
+	return d.Fragments(InstanceScope).Delete(ctx, name)
 }
 
 /****************************** Misc. ************************************************/
@@ -550,51 +643,87 @@ func (d *DestinationClient) SetDebug(debug bool) {
 
 // MarshalJSON marshalls a Destination object as expected by the Destination RESTful API
 func (d Destination) MarshalJSON() ([]byte, error) {
-	if d.Properties == nil {
-		d.Properties = make(map[string]string)
-	}
-	d.Properties["Name"] = d.Name
-	d.Properties["Type"] = string(d.Type)
-	return json.Marshal(d.Properties)
+	return marshalProperties(d.Properties, d.RawProperties, map[string]string{
+		"Name": d.Name,
+		"Type": string(d.Type),
+	})
 }
 
-// UnmarshalJSON unmarshalls a Destination object as provided by the Destination RESTful API
+// UnmarshalJSON unmarshalls a Destination object as provided by the Destination RESTful API.
+// Unknown destination types are kept as they are.
 func (d *Destination) UnmarshalJSON(b []byte) error {
-
-	unmarshalled := map[string]string{}
-	if err := json.Unmarshal(b, &unmarshalled); err != nil {
+	properties, raw, err := unmarshalProperties(b)
+	if err != nil {
 		return err
 	}
-	d.Properties = make(map[string]string)
-	for k, v := range unmarshalled {
-		switch k {
-		case "Name":
-			d.Name = v
-		case "Type":
-			switch v {
-			case "HTTP":
-				d.Type = HTTPDestination
-			case "RFC":
-				d.Type = RFCDestination
-			case "MAIL":
-				d.Type = MailDestination
-			case "LDAP":
-				d.Type = LDAPDestination
-			default:
-				d.Type = ""
-			}
-		default:
-			d.Properties[k] = v
-		}
+	d.Name = properties["Name"]
+	d.Type = DestinationType(properties["Type"])
+	delete(properties, "Name")
+	delete(properties, "Type")
+	d.Properties, d.RawProperties = properties, raw
+	return nil
+}
+
+// MarshalJSON marshalls a Fragment object as expected by the Destination RESTful API
+func (f Fragment) MarshalJSON() ([]byte, error) {
+	return marshalProperties(f.Properties, f.RawProperties, map[string]string{
+		"FragmentName": f.Name,
+	})
+}
+
+// UnmarshalJSON unmarshalls a Fragment object as provided by the Destination RESTful API
+func (f *Fragment) UnmarshalJSON(b []byte) error {
+	properties, raw, err := unmarshalProperties(b)
+	if err != nil {
+		return err
 	}
+	f.Name = properties["FragmentName"]
+	delete(properties, "FragmentName")
+	f.Properties, f.RawProperties = properties, raw
 	return nil
 }
 
-// StatusCode returns the status code provided with the error
-func (e ErrorMessage) StatusCode() int {
-	return e.statusCode
+// marshalProperties encodes the raw and string properties as a single JSON object, followed by the fields that
+// are stored as properties by the service. The given maps are not modified.
+func marshalProperties(properties map[string]string, raw map[string]json.RawMessage, fields map[string]string) ([]byte, error) {
+	merged := make(map[string]json.RawMessage, len(properties)+len(raw)+len(fields))
+	for k, v := range raw {
+		merged[k] = v
+	}
+	for _, values := range []map[string]string{properties, fields} {
+		for k, v := range values {
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			merged[k] = encoded
+		}
+	}
+	return json.Marshal(merged)
 }
 
-func (e ErrorMessage) Error() string {
-	return e.ErrorMessage
+// unmarshalProperties decodes a JSON object, separating string values from values of any other JSON type.
+// The returned string properties are never nil, the raw properties are nil if there are none.
+func unmarshalProperties(b []byte) (map[string]string, map[string]json.RawMessage, error) {
+	var unmarshalled map[string]json.RawMessage
+	if err := json.Unmarshal(b, &unmarshalled); err != nil {
+		return nil, nil, err
+	}
+	properties := make(map[string]string, len(unmarshalled))
+	var raw map[string]json.RawMessage
+	for k, v := range unmarshalled {
+		var value string
+		if len(v) > 0 && v[0] == '"' {
+			if err := json.Unmarshal(v, &value); err != nil {
+				return nil, nil, err
+			}
+			properties[k] = value
+			continue
+		}
+		if raw == nil {
+			raw = make(map[string]json.RawMessage)
+		}
+		raw[k] = v
+	}
+	return properties, raw, nil
 }
@@ -17,7 +17,17 @@ limitations under the License.
 package gosapcpdestinationclient
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
 )
 
 func ExampleNewClient() {
@@ -37,3 +47,166 @@ func ExampleNewClient() {
 	}
 	fmt.Printf("%#v\n", destinations)
 }
+
+// newTestClient starts a stand-in for both the UAA token endpoint and the Destination service. Requests for
+// the service are passed to handler.
+func newTestClient(t *testing.T, handler http.Handler) (*DestinationClient, *httptest.Server) {
+	t.Helper()
+	return newTestClientWithConf(t, handler, nil)
+}
+
+// newTestClientWithConf is like newTestClient, but lets configure modify the client configuration before the client is
+// created with opts
+func newTestClientWithConf(t *testing.T, handler http.Handler, configure func(*DestinationClientConfiguration), opts ...Option) (*DestinationClient, *httptest.Server) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"test-token","token_type":"bearer","expires_in":3600}`)
+	})
+	mux.Handle("/destination-configuration/v1/", http.StripPrefix("/destination-configuration/v1", handler))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	conf := DestinationClientConfiguration{
+		ClientID:     "clientid",
+		ClientSecret: "clientsecret",
+		TokenURL:     server.URL,
+		ServiceURL:   server.URL,
+	}
+	if configure != nil {
+		configure(&conf)
+	}
+	client, err := NewClient(conf, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+func TestFindCtxSendsToken(t *testing.T) {
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/destinations/dest1" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+			t.Errorf("unexpected Authorization header %q", got)
+		}
+		if got := r.Header.Get("X-user-token"); got != "user-token" {
+			t.Errorf("unexpected X-user-token header %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"owner":{"SubaccountId":"sub"},"destinationConfiguration":{"Name":"dest1","Type":"HTTP","URL":"https://example.com"}}`)
+	}))
+
+	result, err := client.FindCtx(context.Background(), "dest1", "user-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Destination.Name != "dest1" || result.Destination.Properties[URLProperty] != "https://example.com" {
+		t.Errorf("unexpected destination %#v", result.Destination)
+	}
+}
+
+func TestCtxCancellation(t *testing.T) {
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request should not reach the service")
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.GetSubaccountDestinationsCtx(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestCustomTransportAndTokenSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/custom/v1/subaccountDestinations" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer static-token" {
+			t.Errorf("unexpected Authorization header %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[]`)
+	}))
+	defer server.Close()
+
+	var requests int
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requests++
+		return http.DefaultTransport.RoundTrip(r)
+	})
+	httpClient := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+
+	client, err := NewClient(DestinationClientConfiguration{
+		ServiceURL:  server.URL,
+		BasePath:    "/custom/v1",
+		HTTPClient:  httpClient,
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "static-token"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetSubaccountDestinations(); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Errorf("expected the custom transport to be used once, got %d", requests)
+	}
+	if httpClient.Transport == nil || httpClient.Timeout != 5*time.Second {
+		t.Error("the provided HTTPClient must not be modified")
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/oauth/token":
+			fmt.Fprint(w, `{"access_token":"test-token","token_type":"bearer","expires_in":3600}`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	client, err := NewClient(DestinationClientConfiguration{
+		ClientID:     "clientid",
+		ClientSecret: "clientsecret",
+		TokenURL:     "http://uaa.invalid",
+		ServiceURL:   "http://destination.invalid",
+		Proxy:        proxyURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetInstanceCertificates(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"http://uaa.invalid/oauth/token", "http://destination.invalid/destination-configuration/v1/instanceCertificates"}
+	if !slices.Equal(proxied, want) {
+		t.Errorf("got proxied requests %v, want %v", proxied, want)
+	}
+}
+
+func TestProxyWithCustomTransport(t *testing.T) {
+	_, err := NewClient(DestinationClientConfiguration{
+		ServiceURL: "http://destination.invalid",
+		Transport:  http.DefaultTransport,
+		Proxy:      &url.URL{Scheme: "http", Host: "proxy.invalid"},
+	})
+	if err == nil {
+		t.Error("expected an error when combining Proxy with a custom Transport")
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,240 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// ConnectivityServiceLabel is the service label of Connectivity service bindings
+const ConnectivityServiceLabel = "connectivity"
+
+// Headers sent to the Connectivity service proxy
+const (
+	// ProxyAuthorizationHeader carries the Connectivity service token
+	ProxyAuthorizationHeader = "Proxy-Authorization"
+	// ConnectivityLocationIDHeader selects the Cloud Connector, from the LocationID property of the destination
+	ConnectivityLocationIDHeader = "SAP-Connectivity-SCC-Location_ID"
+	// ConnectivityAuthenticationHeader carries the user token for principal propagation
+	ConnectivityAuthenticationHeader = "SAP-Connectivity-Authentication"
+)
+
+// ConnectivityConfiguration describes how to reach the Connectivity service proxy
+type ConnectivityConfiguration struct {
+	// OAuth client ID for fetching Connectivity service tokens
+	ClientID string
+	// OAuth client secret
+	ClientSecret string
+	// Base URL of the token endpoint
+	TokenURL string
+	// Host of the Connectivity service proxy
+	ProxyHost string
+	// Port of the HTTP proxy
+	ProxyPort string
+	// Port of the SOCKS5 proxy
+	SOCKS5ProxyPort string
+	// TokenSource provides the Connectivity service tokens. When set, the client credentials and TokenURL are ignored
+	TokenSource oauth2.TokenSource
+}
+
+// connectivityConfiguration builds a ConnectivityConfiguration from the binding credentials
+func (b binding) connectivityConfiguration() (ConnectivityConfiguration, error) {
+	conf := ConnectivityConfiguration{
+		ClientID:        b.credential("clientid"),
+		ClientSecret:    b.credential("clientsecret"),
+		TokenURL:        b.credential("url"),
+		ProxyHost:       b.credential("onpremise_proxy_host"),
+		ProxyPort:       b.credential("onpremise_proxy_http_port"),
+		SOCKS5ProxyPort: b.credential("onpremise_socks5_proxy_port"),
+	}
+	if conf.TokenURL == "" {
+		conf.TokenURL = strings.TrimSuffix(b.credential("token_service_url"), "/")
+	}
+	if conf.ProxyPort == "" {
+		conf.ProxyPort = b.credential("onpremise_proxy_port")
+	}
+	var missing []string
+	for _, c := range []struct{ name, value string }{
+		{"clientid", conf.ClientID},
+		{"clientsecret", conf.ClientSecret},
+		{"url", conf.TokenURL},
+		{"onpremise_proxy_host", conf.ProxyHost},
+		{"onpremise_proxy_http_port", conf.ProxyPort},
+	} {
+		if c.value == "" {
+			missing = append(missing, c.name)
+		}
+	}
+	if len(missing) > 0 {
+		return conf, fmt.Errorf("service binding %q is missing credentials: %s", b.Name, strings.Join(missing, ", "))
+	}
+	return conf, nil
+}
+
+// ParseConnectivityBinding builds a ConnectivityConfiguration from the contents of the VCAP_SERVICES environment variable.
+// By default the single binding of a service labeled ConnectivityServiceLabel is used.
+func ParseConnectivityBinding(vcapServices []byte, opts ...BindingOption) (ConnectivityConfiguration, error) {
+	bindings, err := parseVCAPServices(vcapServices)
+	if err != nil {
+		return ConnectivityConfiguration{}, err
+	}
+	b, err := newBindingFilter(ConnectivityServiceLabel, opts).selectBinding(bindings)
+	if err != nil {
+		return ConnectivityConfiguration{}, err
+	}
+	return b.connectivityConfiguration()
+}
+
+// ParseConnectivityServiceBindingDir builds a ConnectivityConfiguration from service bindings mounted below root.
+// By default the single binding of type ConnectivityServiceLabel is used.
+func ParseConnectivityServiceBindingDir(root string, opts ...BindingOption) (ConnectivityConfiguration, error) {
+	bindings, err := readServiceBindings(root)
+	if err != nil {
+		return ConnectivityConfiguration{}, err
+	}
+	b, err := newBindingFilter(ConnectivityServiceLabel, opts).selectBinding(bindings)
+	if err != nil {
+		return ConnectivityConfiguration{}, err
+	}
+	return b.connectivityConfiguration()
+}
+
+// NewConnectivityProxyFromEnv creates a ConnectivityProxy configured from the Connectivity service binding in the
+// VCAP_SERVICES environment variable
+func NewConnectivityProxyFromEnv(opts ...BindingOption) (*ConnectivityProxy, error) {
+	vcap, ok := os.LookupEnv("VCAP_SERVICES")
+	if !ok {
+		return nil, errors.New("VCAP_SERVICES is not set")
+	}
+	conf, err := ParseConnectivityBinding([]byte(vcap), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewConnectivityProxy(conf)
+}
+
+// ConnectivityProxy sends requests to on-premise systems through the Connectivity service proxy.
+// It is used by Transport for destinations with the OnPremise proxy type.
+type ConnectivityProxy struct {
+	tokens    *tokenTransport
+	transport *http.Transport
+	// tunnelTransport sends HTTPS requests. The proxy headers are only sent when a tunnel is opened, and tunnels are
+	// pooled by target regardless of the headers, so tunnels are not kept alive to avoid reusing one opened for
+	// another user, location or expired token.
+	tunnelTransport *http.Transport
+	socks5Address   string
+}
+
+// proxyHeadersKey is the context key of the headers sent to the proxy when tunneling HTTPS requests
+type proxyHeadersKey struct{}
+
+// NewConnectivityProxy creates a ConnectivityProxy for the proxy described by conf
+func NewConnectivityProxy(conf ConnectivityConfiguration) (*ConnectivityProxy, error) {
+	if conf.ProxyHost == "" || (conf.ProxyPort == "" && conf.SOCKS5ProxyPort == "") {
+		return nil, fmt.Errorf("%w: ProxyHost and ProxyPort or SOCKS5ProxyPort are required", ErrInvalidConfiguration)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	fetch, err := DestinationClientConfiguration{
+		ClientID:     conf.ClientID,
+		ClientSecret: conf.ClientSecret,
+		TokenURL:     conf.TokenURL,
+		TokenSource:  conf.TokenSource,
+	}.tokenFetcher(transport)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ConnectivityProxy{
+		tokens: &tokenTransport{fetch: fetch},
+	}
+	if conf.ProxyPort != "" {
+		proxyURL := &url.URL{Scheme: "http", Host: net.JoinHostPort(conf.ProxyHost, conf.ProxyPort)}
+		p.transport = transport.Clone()
+		p.transport.Proxy = http.ProxyURL(proxyURL)
+		p.tunnelTransport = p.transport.Clone()
+		p.tunnelTransport.DisableKeepAlives = true
+		p.tunnelTransport.GetProxyConnectHeader = func(ctx context.Context, _ *url.URL, _ string) (http.Header, error) {
+			header, _ := ctx.Value(proxyHeadersKey{}).(http.Header)
+			return header, nil
+		}
+	}
+	if conf.SOCKS5ProxyPort != "" {
+		p.socks5Address = net.JoinHostPort(conf.ProxyHost, conf.SOCKS5ProxyPort)
+	}
+	return p, nil
+}
+
+// Token returns a Connectivity service access token, fetching a new one if the cached token has expired
+func (p *ConnectivityProxy) Token(ctx context.Context) (string, error) {
+	token, err := p.tokens.Token(ctx)
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// roundTrip sends a request that was already rewritten for the destination through the proxy. The userToken is
+// forwarded for principal propagation.
+func (p *ConnectivityProxy) roundTrip(req *http.Request, result DestinationLookupResult, userToken string) (*http.Response, error) {
+	if p.transport == nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("%w: ProxyPort is required for HTTP destinations", ErrInvalidConfiguration)
+	}
+	token, err := p.Token(req.Context())
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("fetching connectivity token: %w", err)
+	}
+	header := http.Header{}
+	header.Set(ProxyAuthorizationHeader, "Bearer "+token)
+	if locationID := result.Destination.Properties[LocationIDProperty]; locationID != "" {
+		header.Set(ConnectivityLocationIDHeader, locationID)
+	}
+	if result.Destination.Properties[AuthenticationProperty] == PrincipalPropagationAuthentication {
+		if userToken == "" {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, fmt.Errorf("destination %q uses principal propagation and requires a user token", result.Destination.Name)
+		}
+		header.Set(ConnectivityAuthenticationHeader, "Bearer "+userToken)
+	}
+
+	// Plain HTTP requests carry the proxy headers themselves, HTTPS requests send them when opening the tunnel
+	if req.URL.Scheme == "https" {
+		req = req.WithContext(context.WithValue(req.Context(), proxyHeadersKey{}, header))
+		return p.tunnelTransport.RoundTrip(req)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	return p.transport.RoundTrip(req)
+}
@@ -0,0 +1,274 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseConnectivityBinding(t *testing.T) {
+	conf, err := ParseConnectivityBinding(readFixture(t, "vcap_connectivity.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ConnectivityConfiguration{
+		ClientID:        "sb-clone2!b1|connectivity!b17",
+		ClientSecret:    "secret2",
+		TokenURL:        "https://subdomain.authentication.eu10.hana.ondemand.com",
+		ProxyHost:       "connectivityproxy.internal.cf.eu10.hana.ondemand.com",
+		ProxyPort:       "20003",
+		SOCKS5ProxyPort: "20004",
+	}
+	if conf != want {
+		t.Errorf("unexpected configuration %+v", conf)
+	}
+
+	if _, err := ParseConnectivityBinding(readFixture(t, "vcap_single.json")); !errors.Is(err, ErrBindingNotFound) {
+		t.Errorf("expected ErrBindingNotFound, got %v", err)
+	}
+	vcap := `{"connectivity":[{"name":"broken","credentials":{"clientid":"id","clientsecret":"secret"}}]}`
+	if _, err := ParseConnectivityBinding([]byte(vcap)); err == nil || !strings.Contains(err.Error(), "onpremise_proxy_host") {
+		t.Errorf("expected an error naming the missing credentials, got %v", err)
+	}
+}
+
+// connectivityStandIn starts a token endpoint and a stand-in for the Connectivity service proxy, returning the proxy
+// configuration and the number of tokens fetched
+func connectivityStandIn(t *testing.T, proxy http.HandlerFunc) (ConnectivityConfiguration, *int32) {
+	t.Helper()
+	var tokens int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&tokens, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"connectivity-token-%d","token_type":"bearer","expires_in":3600}`, n)
+	}))
+	t.Cleanup(tokenServer.Close)
+	proxyServer := httptest.NewServer(proxy)
+	t.Cleanup(proxyServer.Close)
+
+	proxyURL, err := url.Parse(proxyServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ConnectivityConfiguration{
+		ClientID:     "connectivity-client",
+		ClientSecret: "secret",
+		TokenURL:     tokenServer.URL,
+		ProxyHost:    proxyURL.Hostname(),
+		ProxyPort:    proxyURL.Port(),
+	}, &tokens
+}
+
+func onPremiseFinder(authentication string) *stubFinder {
+	return &stubFinder{lookup: func(name string, opts FindOptions) (DestinationLookupResult, error) {
+		return DestinationLookupResult{
+			Destination: Destination{Name: name, Type: HTTPDestination, Properties: map[string]string{
+				URLProperty:            "http://virtual-host:44300/sap/opu",
+				ProxyTypeProperty:      OnPremiseProxy,
+				LocationIDProperty:     "berlin",
+				AuthenticationProperty: authentication,
+			}},
+		}, nil
+	}}
+}
+
+func TestTransportOnPremise(t *testing.T) {
+	conf, tokens := connectivityStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.RequestURI != "http://virtual-host:44300/sap/opu/products" {
+			t.Errorf("unexpected request URI %q", r.RequestURI)
+		}
+		if got := r.Header.Get(ProxyAuthorizationHeader); got != "Bearer connectivity-token-1" {
+			t.Errorf("unexpected %s header %q", ProxyAuthorizationHeader, got)
+		}
+		if got := r.Header.Get(ConnectivityLocationIDHeader); got != "berlin" {
+			t.Errorf("unexpected %s header %q", ConnectivityLocationIDHeader, got)
+		}
+		if got := r.Header.Get(ConnectivityAuthenticationHeader); got != "Bearer alice-jwt" {
+			t.Errorf("unexpected %s header %q", ConnectivityAuthenticationHeader, got)
+		}
+		fmt.Fprint(w, "ok")
+	})
+	proxy, err := NewConnectivityProxy(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := NewTransport(onPremiseFinder(PrincipalPropagationAuthentication), "onpremise")
+	transport.Connectivity = proxy
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequestWithContext(ContextWithUserToken(context.Background(), "alice-jwt"), http.MethodGet, "http://ignored/products", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("unexpected status %d", resp.StatusCode)
+		}
+	}
+	if n := atomic.LoadInt32(tokens); n != 1 {
+		t.Errorf("expected the connectivity token to be cached, fetched %d tokens", n)
+	}
+}
+
+func TestTransportOnPremiseHTTPS(t *testing.T) {
+	var tunnel http.Header
+	conf, _ := connectivityStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect || r.Host != "virtual-host:443" {
+			t.Errorf("unexpected tunnel request %s %s", r.Method, r.Host)
+		}
+		tunnel = r.Header.Clone()
+		w.WriteHeader(http.StatusForbidden)
+	})
+	proxy, err := NewConnectivityProxy(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finder := onPremiseFinder(NoAuthentication)
+	finder.lookup = func(name string, _ FindOptions) (DestinationLookupResult, error) {
+		return DestinationLookupResult{Destination: Destination{Name: name, Properties: map[string]string{
+			URLProperty:        "https://virtual-host",
+			ProxyTypeProperty:  OnPremiseProxy,
+			LocationIDProperty: "berlin",
+		}}}, nil
+	}
+	transport := NewTransport(finder, "onpremise")
+	transport.Connectivity = proxy
+
+	if _, err := (&http.Client{Transport: transport}).Get("http://ignored/"); err == nil {
+		t.Fatal("expected the refused tunnel to fail the request")
+	}
+	if got := tunnel.Get(ProxyAuthorizationHeader); got != "Bearer connectivity-token-1" {
+		t.Errorf("unexpected %s tunnel header %q", ProxyAuthorizationHeader, got)
+	}
+	if got := tunnel.Get(ConnectivityLocationIDHeader); got != "berlin" {
+		t.Errorf("unexpected %s tunnel header %q", ConnectivityLocationIDHeader, got)
+	}
+}
+
+func TestTransportOnPremiseHTTPSPerUserTunnels(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer backend.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(backend.Certificate())
+
+	var mu sync.Mutex
+	var tunnels []string
+	conf, _ := connectivityStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			t.Errorf("unexpected %s request to the proxy", r.Method)
+			return
+		}
+		mu.Lock()
+		tunnels = append(tunnels, r.Header.Get(ConnectivityAuthenticationHeader))
+		mu.Unlock()
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer target.Close()
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go io.Copy(target, buf)
+		io.Copy(conn, target)
+	})
+	proxy, err := NewConnectivityProxy(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy.tunnelTransport.TLSClientConfig = &tls.Config{RootCAs: pool}
+
+	finder := onPremiseFinder(PrincipalPropagationAuthentication)
+	finder.lookup = func(name string, _ FindOptions) (DestinationLookupResult, error) {
+		return DestinationLookupResult{Destination: Destination{Name: name, Properties: map[string]string{
+			URLProperty:            backend.URL,
+			ProxyTypeProperty:      OnPremiseProxy,
+			LocationIDProperty:     "berlin",
+			AuthenticationProperty: PrincipalPropagationAuthentication,
+		}}}, nil
+	}
+	transport := NewTransport(finder, "onpremise")
+	transport.Connectivity = proxy
+	client := &http.Client{Transport: transport}
+
+	for _, user := range []string{"alice-jwt", "bob-jwt"} {
+		req, err := http.NewRequestWithContext(ContextWithUserToken(context.Background(), user), http.MethodGet, "http://ignored/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"Bearer alice-jwt", "Bearer bob-jwt"}; !slices.Equal(tunnels, want) {
+		t.Errorf("expected a tunnel per user with %v, got %v", want, tunnels)
+	}
+}
+
+func TestTransportOnPremiseErrors(t *testing.T) {
+	transport := NewTransport(onPremiseFinder(NoAuthentication), "onpremise")
+	if _, err := (&http.Client{Transport: transport}).Get("http://ignored/"); err == nil || !strings.Contains(err.Error(), "connectivity proxy") {
+		t.Errorf("expected an error about the missing connectivity proxy, got %v", err)
+	}
+
+	conf, _ := connectivityStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("no request should reach the proxy")
+	})
+	proxy, err := NewConnectivityProxy(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport = NewTransport(onPremiseFinder(PrincipalPropagationAuthentication), "onpremise")
+	transport.Connectivity = proxy
+	if _, err := (&http.Client{Transport: transport}).Get("http://ignored/"); err == nil || !strings.Contains(err.Error(), "user token") {
+		t.Errorf("expected an error about the missing user token, got %v", err)
+	}
+
+	if _, err := NewConnectivityProxy(ConnectivityConfiguration{}); !errors.Is(err, ErrInvalidConfiguration) {
+		t.Errorf("expected ErrInvalidConfiguration, got %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// getList sends a GET request for the list at path without buffering the response. The caller must close the
+// body of the returned response.
+func getList(ctx context.Context, d *DestinationClient, path string, params map[string]string) (*resty.Response, error) {
+
+	response, err := d.restyClient.R().
+		SetContext(ctx).
+		SetDoNotParseResponse(true).
+		SetQueryParams(params).
+		Get(path)
+
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode() != 200 {
+		body := response.RawBody()
+		defer body.Close()
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		var errResponse ErrorMessage
+		_ = json.Unmarshal(data, &errResponse)
+		response.SetBody(data)
+		return nil, newErrorMessage(response, errResponse)
+	}
+	return response, nil
+}
+
+// readList reads the whole JSON array in the body of response, and closes it
+func readList[T any](response *resty.Response) ([]T, error) {
+	body := response.RawBody()
+	defer body.Close()
+	items := make([]T, 0)
+	_, err := decodeList(newDecoder(body), func(item T) bool {
+		items = append(items, item)
+		return true
+	})
+	return items, err
+}
+
+// newDecoder returns a decoder for list responses. Numbers are kept as json.Number, so non-string property values
+// are decoded without losing precision.
+func newDecoder(r io.Reader) *json.Decoder {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	return decoder
+}
+
+// decodeList reads a JSON array from decoder, passing each item to yield as soon as it is decoded, until yield returns
+// false. It returns the number of items read.
+func decodeList[T any](decoder *json.Decoder, yield func(T) bool) (int, error) {
+	if err := expectDelim(decoder, '['); err != nil {
+		return 0, err
+	}
+	items := 0
+	for decoder.More() {
+		var item T
+		var err error
+		if dest, ok := any(&item).(*Destination); ok {
+			err = dest.decode(decoder)
+		} else {
+			err = decoder.Decode(&item)
+		}
+		if err != nil {
+			return items, err
+		}
+		items++
+		if !yield(item) {
+			return items, nil
+		}
+	}
+	return items, expectDelim(decoder, ']')
+}
+
+// decode reads a single destination from decoder token by token. It is equivalent to UnmarshalJSON, but does not
+// buffer the destination or decode it into an intermediate map first.
+func (d *Destination) decode(decoder *json.Decoder) error {
+	if err := expectDelim(decoder, '{'); err != nil {
+		return err
+	}
+	*d = Destination{Properties: map[string]string{}}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+
+		var raw json.RawMessage
+		if composite, known := nextIsComposite(decoder); composite || !known {
+			// Objects and arrays are kept byte for byte, as by UnmarshalJSON
+			if err := decoder.Decode(&raw); err != nil {
+				return err
+			}
+			if raw[0] == '"' {
+				var value string
+				if err := json.Unmarshal(raw, &value); err != nil {
+					return err
+				}
+				d.setProperty(key, value)
+				continue
+			}
+		} else {
+			if token, err = decoder.Token(); err != nil {
+				return err
+			}
+			if value, ok := token.(string); ok {
+				d.setProperty(key, value)
+				continue
+			}
+			raw = rawToken(token)
+		}
+		if d.RawProperties == nil {
+			d.RawProperties = map[string]json.RawMessage{}
+		}
+		d.RawProperties[key] = raw
+	}
+	return expectDelim(decoder, '}')
+}
+
+// setProperty sets a string property read by decode
+func (d *Destination) setProperty(key string, value string) {
+	switch key {
+	case "Name":
+		d.Name = value
+	case "Type":
+		d.Type = DestinationType(value)
+	default:
+		d.Properties[key] = value
+	}
+}
+
+// nextIsComposite reports whether the next value of decoder is an object or an array, judging from the buffered
+// input. known is false if the value does not start in the buffered input.
+func nextIsComposite(decoder *json.Decoder) (composite bool, known bool) {
+	buffered, ok := decoder.Buffered().(io.ByteReader)
+	if !ok {
+		return false, false
+	}
+	for {
+		c, err := buffered.ReadByte()
+		if err != nil {
+			return false, false
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n', ':':
+			continue
+		case '{', '[':
+			return true, true
+		}
+		return false, true
+	}
+}
+
+// rawToken returns the JSON encoding of a literal token
+func rawToken(token json.Token) json.RawMessage {
+	switch value := token.(type) {
+	case bool:
+		return strconv.AppendBool(nil, value)
+	case json.Number:
+		return json.RawMessage(value)
+	case float64:
+		return strconv.AppendFloat(nil, value, 'g', -1, 64)
+	}
+	return json.RawMessage("null")
+}
+
+// expectDelim reads the next token from decoder, which must be delim
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v in JSON input, got %v", delim, token)
+	}
+	return nil
+}
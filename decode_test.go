@@ -0,0 +1,136 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecodeDestinations(t *testing.T) {
+	data := `[
+		{"Name":"dest1","Type":"HTTP","URL":"https://example.com","Escaped":"a\"bé"},
+		{"Name":"dest2","Type":"TCP","Port":8080,"Ratio":0.25,"Big":12345678901234567890,"Enabled":false,"Empty":null,
+		 "Nested":{"a":[1,"b",{"c":null}],"d":true},"List":[]},
+		{"Name" : "dest3", "Type":"HTTP", "Unsorted": { "z": "<b>&amp;</b>",
+			"a" : [ 2, 1 ] }, "Html":"<script>"},
+		{}
+	]`
+	var want []Destination
+	if err := json.Unmarshal([]byte(data), &want); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(want[2].RawProperties["Unsorted"]); !strings.HasPrefix(got, `{ "z": "<b>&amp;</b>",`) {
+		t.Fatalf("UnmarshalJSON did not keep the raw bytes: %s", got)
+	}
+	for name, r := range map[string]io.Reader{
+		"buffered":    strings.NewReader(data),
+		"one byte":    iotest.OneByteReader(strings.NewReader(data)),
+		"half buffer": iotest.HalfReader(strings.NewReader(data)),
+	} {
+		got, err := readTestList(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: streaming decoding differs from UnmarshalJSON\n got %#v\nwant %#v", name, got, want)
+		}
+	}
+}
+
+func TestDecodeDestinationsErrors(t *testing.T) {
+	for _, data := range []string{
+		``,
+		`{"Name":"dest1"}`,
+		`[{"Name":"dest1"}`,
+		`[{"Name":"dest1",}]`,
+		`[{"Name":"dest1","Nested":{"a":[1,}}]`,
+		`["dest1"]`,
+	} {
+		if _, err := readTestList(strings.NewReader(data)); err == nil {
+			t.Errorf("expected an error decoding %q", data)
+		}
+	}
+}
+
+// readTestList decodes a list of destinations like the list calls of the client
+func readTestList(r io.Reader) ([]Destination, error) {
+	var items []Destination
+	_, err := decodeList(newDecoder(r), func(item Destination) bool {
+		items = append(items, item)
+		return true
+	})
+	return items, err
+}
+
+// destinationList returns the JSON encoding of n destinations with the given number of properties each
+func destinationList(n int, properties int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, `{"Name":"destination-%d","Type":"HTTP","URL":"https://backend-%d.example.com/api"`, i, i)
+		for p := 0; p < properties; p++ {
+			fmt.Fprintf(&buf, `,"property.%d":"value of property %d"`, p, p)
+		}
+		buf.WriteString(`,"Timeout":30}`)
+	}
+	buf.WriteString("]")
+	return buf.Bytes()
+}
+
+// BenchmarkDecodeDestinations compares the buffered decoding of a list response, as done by resty with
+// UnmarshalJSON, with the streaming decoding used by the list calls
+func BenchmarkDecodeDestinations(b *testing.B) {
+	data := destinationList(1000, 20)
+	b.Run("buffered", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			body, err := io.ReadAll(bytes.NewReader(data))
+			if err != nil {
+				b.Fatal(err)
+			}
+			var destinations []Destination
+			if err := json.Unmarshal(body, &destinations); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("streaming", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			destinations := make([]Destination, 0)
+			_, err := decodeList(newDecoder(bytes.NewReader(data)), func(item Destination) bool {
+				destinations = append(destinations, item)
+				return true
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
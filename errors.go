@@ -0,0 +1,136 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Sentinel errors matched by ErrorMessage through errors.Is, based on the status code returned by the Destination API
+var (
+	// ErrNotFound is matched by errors for 404 responses
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists is matched by errors for 409 responses
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrUnauthorized is matched by errors for 401 responses
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is matched by errors for 403 responses
+	ErrForbidden = errors.New("forbidden")
+	// ErrRateLimited is matched by errors for 429 responses
+	ErrRateLimited = errors.New("rate limited")
+	// ErrServerError is matched by errors for 5xx responses
+	ErrServerError = errors.New("server error")
+	// ErrTokenRetrieval is matched by AuthTokenError
+	ErrTokenRetrieval = errors.New("token retrieval failed")
+)
+
+// maxErrorBodyMessage limits how much of a non-JSON error body is used as the error message
+const maxErrorBodyMessage = 256
+
+var htmlTitle = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// newErrorMessage completes the ErrorMessage decoded from an unexpected response with the details of the request and response
+func newErrorMessage(response *resty.Response, errResponse ErrorMessage) ErrorMessage {
+	errResponse.statusCode = response.StatusCode()
+	errResponse.Method = response.Request.Method
+	if response.Request.RawRequest != nil {
+		errResponse.Path = response.Request.RawRequest.URL.Path
+	} else {
+		errResponse.Path = response.Request.URL
+	}
+	errResponse.Body = response.Body()
+	errResponse.CorrelationID = response.Header().Get("x-correlationid")
+	errResponse.VCAPRequestID = response.Header().Get("x-vcap-request-id")
+	if errResponse.ErrorMessage == "" {
+		errResponse.ErrorMessage = bodyMessage(errResponse.statusCode, errResponse.Body)
+	}
+	return errResponse
+}
+
+// bodyMessage builds an error message for responses that did not carry a JSON ErrorMessage, e.g. HTML pages returned by a gateway
+func bodyMessage(statusCode int, body []byte) string {
+	message := fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode))
+	text := string(body)
+	if match := htmlTitle.FindStringSubmatch(text); match != nil {
+		text = match[1]
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > maxErrorBodyMessage {
+		text = string(runes[:maxErrorBodyMessage]) + "..."
+	}
+	switch {
+	case text == "":
+		return message
+	case strings.HasPrefix(text, message):
+		return text
+	}
+	return message + ": " + text
+}
+
+// StatusCode returns the status code provided with the error
+func (e ErrorMessage) StatusCode() int {
+	return e.statusCode
+}
+
+func (e ErrorMessage) Error() string {
+	return e.ErrorMessage
+}
+
+// Is reports whether the error matches one of the sentinel errors defined by this package
+func (e ErrorMessage) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.statusCode == http.StatusNotFound
+	case ErrAlreadyExists:
+		return e.statusCode == http.StatusConflict
+	case ErrUnauthorized:
+		return e.statusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.statusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.statusCode == http.StatusTooManyRequests
+	case ErrServerError:
+		return e.statusCode >= 500 && e.statusCode <= 599
+	}
+	return false
+}
+
+// AuthTokenError reports that the service did not retrieve the authentication tokens of a destination
+type AuthTokenError struct {
+	// Name of the destination
+	Destination string
+	// Errors reported by the service for the failed tokens. Empty if no tokens were returned at all
+	Errors []string
+}
+
+func (e *AuthTokenError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("%s for destination %q: no tokens returned", ErrTokenRetrieval, e.Destination)
+	}
+	return fmt.Sprintf("%s for destination %q: %s", ErrTokenRetrieval, e.Destination, strings.Join(e.Errors, "; "))
+}
+
+// Is matches ErrTokenRetrieval
+func (e *AuthTokenError) Is(target error) bool {
+	return target == ErrTokenRetrieval
+}
@@ -0,0 +1,89 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestErrorMessageSentinels(t *testing.T) {
+	tests := []struct {
+		status   int
+		sentinel error
+	}{
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusConflict, ErrAlreadyExists},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusBadGateway, ErrServerError},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("x-correlationid", "corr-1")
+				w.Header().Set("x-vcap-request-id", "vcap-1")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, `{"ErrorMessage":"something failed"}`)
+			}))
+
+			_, err := client.GetSubaccountDestination("dest1")
+			if !errors.Is(err, tt.sentinel) {
+				t.Fatalf("expected %v, got %v", tt.sentinel, err)
+			}
+			if errors.Is(err, ErrForbidden) != (tt.sentinel == ErrForbidden) {
+				t.Errorf("unexpected match of ErrForbidden for %d", tt.status)
+			}
+			var errMessage ErrorMessage
+			if !errors.As(err, &errMessage) {
+				t.Fatalf("expected an ErrorMessage, got %T", err)
+			}
+			if errMessage.ErrorMessage != "something failed" || errMessage.StatusCode() != tt.status {
+				t.Errorf("unexpected error %#v", errMessage)
+			}
+			if errMessage.Method != http.MethodGet || errMessage.Path != "/destination-configuration/v1/subaccountDestinations/dest1" {
+				t.Errorf("unexpected request details %s %s", errMessage.Method, errMessage.Path)
+			}
+			if errMessage.CorrelationID != "corr-1" || errMessage.VCAPRequestID != "vcap-1" {
+				t.Errorf("unexpected correlation headers %q %q", errMessage.CorrelationID, errMessage.VCAPRequestID)
+			}
+		})
+	}
+}
+
+func TestErrorMessageNonJSONBody(t *testing.T) {
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, "<html><head><title>502 Bad Gateway</title></head><body>upstream unavailable</body></html>")
+	}))
+
+	_, err := client.GetSubaccountDestinations()
+	if !errors.Is(err, ErrServerError) {
+		t.Fatalf("expected ErrServerError, got %v", err)
+	}
+	if got, want := err.Error(), "502 Bad Gateway"; got != want {
+		t.Errorf("got message %q, want %q", got, want)
+	}
+	if len(err.(ErrorMessage).Body) == 0 {
+		t.Error("expected the raw body to be captured")
+	}
+}
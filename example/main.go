@@ -21,24 +21,14 @@ import (
 	"os"
 
 	destinations "github.com/liorokman/go-sapcp-destination-client"
-	"github.com/tidwall/gjson"
 )
 
 func main() {
 
-	vcap := os.Getenv("VCAP_SERVICES")
-	if vcap == "" {
-		fmt.Printf("No VCAP_SERVICES")
-		os.Exit(1)
-	}
-	destinationClient, err := destinations.NewClient(destinations.DestinationClientConfiguration{
-		ClientID:     gjson.Get(vcap, "destination.0.credentials.clientid").String(),
-		ClientSecret: gjson.Get(vcap, "destination.0.credentials.clientsecret").String(),
-		TokenURL:     gjson.Get(vcap, "destination.0.credentials.url").String(),
-		ServiceURL:   gjson.Get(vcap, "destination.0.credentials.uri").String(),
-	})
+	destinationClient, err := destinations.NewClientFromEnv()
 	if err != nil {
-		panic(err)
+		fmt.Println(err)
+		os.Exit(1)
 	}
 	destinations, err := destinationClient.GetSubaccountDestinations()
 	if err != nil {
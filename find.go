@@ -0,0 +1,102 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// FindOptions contains the optional values passed to the service when finding a destination
+type FindOptions struct {
+	// UserToken is passed as the X-user-token header, for destinations that exchange the user's token
+	UserToken string
+	// RefreshToken is passed as the X-refresh-token header, for destinations that use a refresh token to obtain the access token
+	RefreshToken string
+	// Tenant is passed as the X-tenant header, to look up the destination on behalf of a subscriber subaccount (by subdomain or tenant ID)
+	Tenant string
+	// FragmentName is passed as the X-fragment-name header, to merge the named destination fragment into the destination
+	FragmentName string
+	// FragmentOptional is passed as the X-fragment-optional header, so the lookup does not fail if the fragment does not exist
+	FragmentOptional bool
+	// Code is passed as the X-code header, with the authorization code of OAuth2AuthorizationCode destinations
+	Code string
+	// RedirectURI is passed as the X-redirect-uri header, with the redirect URI used when obtaining Code
+	RedirectURI string
+	// CodeVerifier is passed as the X-code-verifier header, with the PKCE code verifier used when obtaining Code
+	CodeVerifier string
+	// ChainName is passed as the X-chain-name header, to process the destination with a predefined destination chain
+	ChainName string
+	// ChainVariables are passed as X-chain-var-<name> headers, with the variables required by the destination chain
+	ChainVariables map[string]string
+	// SkipTokenRetrieval asks the service to return the destination without retrieving authentication tokens
+	SkipTokenRetrieval bool
+	// SkipCredentialsProcessing asks the service to return the destination without processing credentials, e.g. certificates
+	SkipCredentialsProcessing bool
+}
+
+// headers returns the request headers for the options
+func (o FindOptions) headers() map[string]string {
+	headers := map[string]string{}
+	for header, value := range map[string]string{
+		"X-user-token":    o.UserToken,
+		"X-refresh-token": o.RefreshToken,
+		"X-tenant":        o.Tenant,
+		"X-fragment-name": o.FragmentName,
+		"X-code":          o.Code,
+		"X-redirect-uri":  o.RedirectURI,
+		"X-code-verifier": o.CodeVerifier,
+		"X-chain-name":    o.ChainName,
+	} {
+		if value != "" {
+			headers[header] = value
+		}
+	}
+	if o.FragmentOptional {
+		headers["X-fragment-optional"] = "true"
+	}
+	for name, value := range o.ChainVariables {
+		headers["X-chain-var-"+name] = value
+	}
+	return headers
+}
+
+// queryParams returns the query parameters for the options
+func (o FindOptions) queryParams() map[string]string {
+	params := map[string]string{}
+	if o.SkipTokenRetrieval {
+		params["$skipTokenRetrieval"] = "true"
+	}
+	if o.SkipCredentialsProcessing {
+		params["$skipCredentialsProcessing"] = "true"
+	}
+	return params
+}
+
+// cacheKey returns the key of a lookup of the named destination with these options
+func (o FindOptions) cacheKey(name string) string {
+	parts := []string{
+		name, o.UserToken, o.RefreshToken, o.Tenant, o.FragmentName, strconv.FormatBool(o.FragmentOptional),
+		o.Code, o.RedirectURI, o.CodeVerifier, o.ChainName,
+		strconv.FormatBool(o.SkipTokenRetrieval), strconv.FormatBool(o.SkipCredentialsProcessing),
+	}
+	for _, name := range slices.Sorted(maps.Keys(o.ChainVariables)) {
+		parts = append(parts, name, o.ChainVariables[name])
+	}
+	return findCacheKey(parts...)
+}
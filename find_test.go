@@ -0,0 +1,102 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFindWithOptions(t *testing.T) {
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for header, want := range map[string]string{
+			"X-user-token":             "",
+			"X-refresh-token":          "refresh",
+			"X-tenant":                 "subscriber",
+			"X-fragment-name":          "fragment1",
+			"X-fragment-optional":      "true",
+			"X-code":                   "auth-code",
+			"X-redirect-uri":           "https://app.example.com/callback",
+			"X-code-verifier":          "verifier",
+			"X-chain-name":             "chain1",
+			"X-chain-var-subjectToken": "subject",
+		} {
+			if got := r.Header.Get(header); got != want {
+				t.Errorf("got %s %q, want %q", header, got, want)
+			}
+		}
+		for _, param := range []string{"$skipTokenRetrieval", "$skipCredentialsProcessing"} {
+			if got := r.URL.Query().Get(param); got != "true" {
+				t.Errorf("unexpected %s %q", param, got)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"destinationConfiguration":{"Name":"dest1","Type":"HTTP"}}`)
+	}))
+
+	var finder DestinationFinder = client
+	result, err := finder.FindWithOptions(context.Background(), "dest1", FindOptions{
+		RefreshToken:              "refresh",
+		Tenant:                    "subscriber",
+		FragmentName:              "fragment1",
+		FragmentOptional:          true,
+		Code:                      "auth-code",
+		RedirectURI:               "https://app.example.com/callback",
+		CodeVerifier:              "verifier",
+		ChainName:                 "chain1",
+		ChainVariables:            map[string]string{"subjectToken": "subject"},
+		SkipTokenRetrieval:        true,
+		SkipCredentialsProcessing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Destination.Name != "dest1" {
+		t.Errorf("unexpected destination %#v", result.Destination)
+	}
+}
+
+func TestFindDoesNotCacheTokenErrors(t *testing.T) {
+	finds := 0
+	client, _ := newTestClientWithConf(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		finds++
+		w.Header().Set("Content-Type", "application/json")
+		if finds == 1 {
+			fmt.Fprint(w, `{"destinationConfiguration":{"Name":"dest1","Type":"HTTP","Authentication":"OAuth2ClientCredentials"},`+
+				`"authTokens":[{"type":"","value":"","error":"token service unavailable"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"destinationConfiguration":{"Name":"dest1","Type":"HTTP","Authentication":"OAuth2ClientCredentials"},`+
+			`"authTokens":[{"type":"Bearer","value":"token","expires_in":3600}]}`)
+	}), nil, WithCache(NewMemoryCache(time.Hour)))
+
+	for i, wantErr := range []bool{true, false, false} {
+		result, err := client.FindWithOptions(context.Background(), "dest1", FindOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (result.TokenError() != nil) != wantErr {
+			t.Errorf("lookup %d: unexpected token error %v", i, result.TokenError())
+		}
+	}
+	if finds != 2 {
+		t.Errorf("expected the failed lookup to be repeated and the successful one cached, got %d requests", finds)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestFragmentJSON(t *testing.T) {
+	fragment := Fragment{
+		Name:       "fragment1",
+		Properties: map[string]string{URLProperty: "https://override.example.com"},
+	}
+	data, err := json.Marshal(fragment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"FragmentName":"fragment1","URL":"https://override.example.com"}`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+	if _, ok := fragment.Properties["FragmentName"]; ok {
+		t.Error("MarshalJSON must not modify the fragment properties")
+	}
+
+	var decoded Fragment
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, fragment) {
+		t.Errorf("got %#v, want %#v", decoded, fragment)
+	}
+
+	data = []byte(`{"FragmentName":"fragment1","Port":443,"Secure":true}`)
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Properties) != 0 || string(decoded.RawProperties["Port"]) != "443" || string(decoded.RawProperties["Secure"]) != "true" {
+		t.Errorf("unexpected fragment %#v", decoded)
+	}
+	if encoded, err := json.Marshal(decoded); err != nil || string(encoded) != string(data) {
+		t.Errorf("got %s, want %s (%v)", encoded, data, err)
+	}
+}
+
+func TestFragmentManagement(t *testing.T) {
+	var requests []string
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, body))
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			if r.URL.Path == "/instanceDestinationFragments" || r.URL.Path == "/subaccountDestinationFragments" {
+				fmt.Fprint(w, `[{"FragmentName":"fragment1","User":"user1"}]`)
+			} else {
+				fmt.Fprint(w, `{"FragmentName":"fragment1","User":"user1"}`)
+			}
+		default:
+			fmt.Fprint(w, `{"Count":1}`)
+		}
+	}))
+
+	fragment := Fragment{Name: "fragment1", Properties: map[string]string{UserProperty: "user1"}}
+	var managers = []struct {
+		list   func() ([]Fragment, error)
+		create func(Fragment) error
+		update func(Fragment) (AffectedRecords, error)
+		get    func(string) (Fragment, error)
+		delete func(string) (AffectedRecords, error)
+	}{
+		{client.GetSubaccountFragments, client.CreateSubaccountFragment, client.UpdateSubaccountFragment, client.GetSubaccountFragment, client.DeleteSubaccountFragment},
+		{client.GetInstanceFragments, client.CreateInstanceFragment, client.UpdateInstanceFragment, client.GetInstanceFragment, client.DeleteInstanceFragment},
+	}
+	for _, m := range managers {
+		fragments, err := m.list()
+		if err != nil || len(fragments) != 1 || !reflect.DeepEqual(fragments[0], fragment) {
+			t.Errorf("unexpected fragments %#v, %v", fragments, err)
+		}
+		if err := m.create(fragment); err != nil {
+			t.Error(err)
+		}
+		if affected, err := m.update(fragment); err != nil || affected.Count != 1 {
+			t.Errorf("unexpected update result %#v, %v", affected, err)
+		}
+		if got, err := m.get("fragment1"); err != nil || !reflect.DeepEqual(got, fragment) {
+			t.Errorf("unexpected fragment %#v, %v", got, err)
+		}
+		if affected, err := m.delete("fragment1"); err != nil || affected.Count != 1 {
+			t.Errorf("unexpected delete result %#v, %v", affected, err)
+		}
+	}
+
+	body := `{"FragmentName":"fragment1","User":"user1"}`
+	want := []string{
+		"GET /subaccountDestinationFragments ",
+		"POST /subaccountDestinationFragments " + body,
+		"PUT /subaccountDestinationFragments " + body,
+		"GET /subaccountDestinationFragments/fragment1 ",
+		"DELETE /subaccountDestinationFragments/fragment1 ",
+		"GET /instanceDestinationFragments ",
+		"POST /instanceDestinationFragments " + body,
+		"PUT /instanceDestinationFragments " + body,
+		"GET /instanceDestinationFragments/fragment1 ",
+		"DELETE /instanceDestinationFragments/fragment1 ",
+	}
+	if !slices.Equal(requests, want) {
+		t.Errorf("got requests\n%v\nwant\n%v", requests, want)
+	}
+}
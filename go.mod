@@ -6,12 +6,11 @@ toolchain go1.24.4
 
 require (
 	github.com/go-resty/resty/v2 v2.16.2
-	github.com/tidwall/gjson v1.18.0
 	golang.org/x/oauth2 v0.30.0
+	software.sslmate.com/src/go-pkcs12 v0.7.3
 )
 
 require (
-	github.com/tidwall/match v1.1.1 // indirect
-	github.com/tidwall/pretty v1.2.1 // indirect
+	golang.org/x/crypto v0.40.0 // indirect
 	golang.org/x/net v0.42.0 // indirect
 )
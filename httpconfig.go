@@ -0,0 +1,265 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// Prefixes of the destination properties holding additional HTTP headers and query parameters
+const (
+	HeaderPropertyPrefix = "URL.headers."
+	QueryPropertyPrefix  = "URL.queries."
+)
+
+// HTTPDestinationConfig is the typed configuration of an HTTP destination.
+// String properties are copied into the fields without conversion. Properties the configuration does not model stay in
+// AdditionalProperties, and non-string JSON values in RawProperties, so NewHTTPDestinationConfig followed by
+// Destination returns the original destination.
+type HTTPDestinationConfig struct {
+	// The name of the destination
+	Name string
+	// Description property
+	Description string
+	// URL property
+	URL string
+	// Authentication property, one of the authentication constants
+	Authentication string
+	// ProxyType property, InternetProxy or OnPremiseProxy
+	ProxyType string
+	// LocationID property, selecting the Cloud Connector of OnPremise destinations
+	LocationID string
+
+	// Used by BasicAuthentication and OAuth2Password
+	Basic BasicCredentials
+	// Used by the OAuth2 authentication types
+	OAuth2 OAuth2Settings
+	// Used by OAuth2SAMLBearerAssertion
+	SAML SAMLSettings
+	// Used by SAPAssertionSSO
+	AssertionSSO AssertionSSOSettings
+	// Client certificate and server verification settings
+	TLS TLSSettings
+
+	// Headers added to every request, from the URL.headers.<name> properties
+	Headers map[string]string
+	// Query parameters added to every request, from the URL.queries.<name> properties
+	Queries map[string]string
+	// Properties that have no field
+	AdditionalProperties map[string]string
+	// Properties whose values are not JSON strings, from Destination.RawProperties
+	RawProperties map[string]json.RawMessage
+}
+
+// BasicCredentials are the User and Password properties
+type BasicCredentials struct {
+	User     string
+	Password string
+}
+
+// OAuth2Settings are the properties describing the OAuth2 token service
+type OAuth2Settings struct {
+	// clientId property
+	ClientID string
+	// clientSecret property
+	ClientSecret string
+	// tokenServiceURL property
+	TokenServiceURL string
+	// tokenServiceURLType property, Dedicated or Common
+	TokenServiceURLType string
+	// tokenServiceUser property
+	TokenServiceUser string
+	// tokenServicePassword property
+	TokenServicePassword string
+	// scope property
+	Scope string
+	// tokenService.KeyStoreLocation property, for authenticating at the token service with a client certificate
+	TokenServiceKeyStoreLocation string
+	// tokenService.KeyStorePassword property
+	TokenServiceKeyStorePassword string
+}
+
+// SAMLSettings are the properties of the SAML assertion issued for OAuth2SAMLBearerAssertion
+type SAMLSettings struct {
+	// audience property
+	Audience string
+	// authnContextClassRef property
+	AuthnContextClassRef string
+	// nameIdFormat property
+	NameIDFormat string
+	// userIdSource property
+	UserIDSource string
+	// assertionIssuer property
+	AssertionIssuer string
+}
+
+// AssertionSSOSettings are the properties of the assertion ticket issued for SAPAssertionSSO
+type AssertionSSOSettings struct {
+	// IssuerSID property
+	IssuerSID string
+	// IssuerClient property
+	IssuerClient string
+	// RecipientSID property
+	RecipientSID string
+	// RecipientClient property
+	RecipientClient string
+	// Certificate property
+	Certificate string
+	// SigningKey property
+	SigningKey string
+}
+
+// TLSSettings are the keystore and truststore properties
+type TLSSettings struct {
+	KeyStoreLocation   string
+	KeyStorePassword   string
+	TrustStoreLocation string
+	TrustStorePassword string
+	// TrustAll property, "true" or "false"
+	TrustAll string
+	// HostnameVerifier property
+	HostnameVerifier string
+}
+
+// configProperty maps a destination property to a field of a typed configuration
+type configProperty struct {
+	name  string
+	value *string
+}
+
+func (c *HTTPDestinationConfig) properties() []configProperty {
+	return []configProperty{
+		{DescriptionProperty, &c.Description},
+		{URLProperty, &c.URL},
+		{AuthenticationProperty, &c.Authentication},
+		{ProxyTypeProperty, &c.ProxyType},
+		{LocationIDProperty, &c.LocationID},
+		{UserProperty, &c.Basic.User},
+		{PasswordProperty, &c.Basic.Password},
+		{"clientId", &c.OAuth2.ClientID},
+		{"clientSecret", &c.OAuth2.ClientSecret},
+		{"tokenServiceURL", &c.OAuth2.TokenServiceURL},
+		{"tokenServiceURLType", &c.OAuth2.TokenServiceURLType},
+		{"tokenServiceUser", &c.OAuth2.TokenServiceUser},
+		{"tokenServicePassword", &c.OAuth2.TokenServicePassword},
+		{"scope", &c.OAuth2.Scope},
+		{"tokenService.KeyStoreLocation", &c.OAuth2.TokenServiceKeyStoreLocation},
+		{"tokenService.KeyStorePassword", &c.OAuth2.TokenServiceKeyStorePassword},
+		{"audience", &c.SAML.Audience},
+		{"authnContextClassRef", &c.SAML.AuthnContextClassRef},
+		{"nameIdFormat", &c.SAML.NameIDFormat},
+		{"userIdSource", &c.SAML.UserIDSource},
+		{"assertionIssuer", &c.SAML.AssertionIssuer},
+		{"IssuerSID", &c.AssertionSSO.IssuerSID},
+		{"IssuerClient", &c.AssertionSSO.IssuerClient},
+		{"RecipientSID", &c.AssertionSSO.RecipientSID},
+		{"RecipientClient", &c.AssertionSSO.RecipientClient},
+		{"Certificate", &c.AssertionSSO.Certificate},
+		{"SigningKey", &c.AssertionSSO.SigningKey},
+		{KeyStoreLocationProperty, &c.TLS.KeyStoreLocation},
+		{KeyStorePasswordProperty, &c.TLS.KeyStorePassword},
+		{TrustStoreLocationProperty, &c.TLS.TrustStoreLocation},
+		{TrustStorePasswordProperty, &c.TLS.TrustStorePassword},
+		{TrustAllProperty, &c.TLS.TrustAll},
+		{HostnameVerifierProperty, &c.TLS.HostnameVerifier},
+	}
+}
+
+// NewHTTPDestinationConfig converts a destination of type HTTP to its typed configuration
+func NewHTTPDestinationConfig(d Destination) (HTTPDestinationConfig, error) {
+	if d.Type != HTTPDestination {
+		return HTTPDestinationConfig{}, fmt.Errorf("destination %q has type %q, expected %q", d.Name, d.Type, HTTPDestination)
+	}
+	c := HTTPDestinationConfig{Name: d.Name, RawProperties: maps.Clone(d.RawProperties)}
+	rest := readConfigProperties(d.Properties, c.properties())
+	for name, value := range rest {
+		switch {
+		case strings.HasPrefix(name, HeaderPropertyPrefix):
+			if c.Headers == nil {
+				c.Headers = map[string]string{}
+			}
+			c.Headers[strings.TrimPrefix(name, HeaderPropertyPrefix)] = value
+		case strings.HasPrefix(name, QueryPropertyPrefix):
+			if c.Queries == nil {
+				c.Queries = map[string]string{}
+			}
+			c.Queries[strings.TrimPrefix(name, QueryPropertyPrefix)] = value
+		default:
+			continue
+		}
+		delete(rest, name)
+	}
+	if len(rest) > 0 {
+		c.AdditionalProperties = rest
+	}
+	return c, nil
+}
+
+// Destination converts the typed configuration to a Destination of type HTTP
+func (c HTTPDestinationConfig) Destination() Destination {
+	properties := writeConfigProperties(c.AdditionalProperties, c.properties())
+	for name, value := range c.Headers {
+		properties[HeaderPropertyPrefix+name] = value
+	}
+	for name, value := range c.Queries {
+		properties[QueryPropertyPrefix+name] = value
+	}
+	return Destination{
+		Name:          c.Name,
+		Type:          HTTPDestination,
+		Properties:    properties,
+		RawProperties: maps.Clone(c.RawProperties),
+	}
+}
+
+// readConfigProperties sets the fields of a typed configuration from the destination properties, and returns the
+// properties that were not stored in a field. Properties with an empty value are returned as well, so they are kept
+// when converting back.
+func readConfigProperties(properties map[string]string, fields []configProperty) map[string]string {
+	rest := make(map[string]string, len(properties))
+	for name, value := range properties {
+		rest[name] = value
+	}
+	for _, field := range fields {
+		if value := rest[field.name]; value != "" {
+			*field.value = value
+			delete(rest, field.name)
+		}
+	}
+	if len(rest) == 0 {
+		return nil
+	}
+	return rest
+}
+
+// writeConfigProperties returns the destination properties of a typed configuration, starting with the additional
+// properties. Fields with an empty value are omitted.
+func writeConfigProperties(additional map[string]string, fields []configProperty) map[string]string {
+	properties := make(map[string]string, len(additional)+len(fields))
+	for name, value := range additional {
+		properties[name] = value
+	}
+	for _, field := range fields {
+		if *field.value != "" {
+			properties[field.name] = *field.value
+		}
+	}
+	return properties
+}
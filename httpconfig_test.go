@@ -0,0 +1,170 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPDestinationConfig(t *testing.T) {
+	dest := Destination{
+		Name: "s4",
+		Type: HTTPDestination,
+		Properties: map[string]string{
+			URLProperty:                  "https://s4.example.com/sap/opu/odata",
+			AuthenticationProperty:       OAuth2ClientCredentialsAuthentication,
+			ProxyTypeProperty:            InternetProxy,
+			"clientId":                   "client",
+			"clientSecret":               "secret",
+			"tokenServiceURL":            "https://auth.example.com/oauth/token",
+			"tokenServiceURLType":        "Dedicated",
+			TrustAllProperty:             "false",
+			"URL.headers.x-custom":       "value",
+			"URL.headers.x-empty":        "",
+			"URL.queries.sap-client":     "100",
+			"WebIDEEnabled":              "true",
+			"HTML5.DynamicDestination":   "true",
+			"tokenService.body.resource": "urn:s4",
+			DescriptionProperty:          "",
+		},
+	}
+	conf, err := NewHTTPDestinationConfig(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := HTTPDestinationConfig{
+		Name:           "s4",
+		URL:            "https://s4.example.com/sap/opu/odata",
+		Authentication: OAuth2ClientCredentialsAuthentication,
+		ProxyType:      InternetProxy,
+		OAuth2: OAuth2Settings{
+			ClientID:            "client",
+			ClientSecret:        "secret",
+			TokenServiceURL:     "https://auth.example.com/oauth/token",
+			TokenServiceURLType: "Dedicated",
+		},
+		TLS:     TLSSettings{TrustAll: "false"},
+		Headers: map[string]string{"x-custom": "value", "x-empty": ""},
+		Queries: map[string]string{"sap-client": "100"},
+		AdditionalProperties: map[string]string{
+			"WebIDEEnabled":              "true",
+			"HTML5.DynamicDestination":   "true",
+			"tokenService.body.resource": "urn:s4",
+			DescriptionProperty:          "",
+		},
+	}
+	if !reflect.DeepEqual(conf, want) {
+		t.Errorf("unexpected configuration\n got %+v\nwant %+v", conf, want)
+	}
+	if got := conf.Destination(); !reflect.DeepEqual(got, dest) {
+		t.Errorf("round trip changed the destination\n got %+v\nwant %+v", got, dest)
+	}
+}
+
+func TestHTTPDestinationConfigToDestination(t *testing.T) {
+	conf := HTTPDestinationConfig{
+		Name:           "basic",
+		URL:            "http://virtual-host:8000",
+		Authentication: BasicAuthentication,
+		ProxyType:      OnPremiseProxy,
+		LocationID:     "berlin",
+		Basic:          BasicCredentials{User: "alice", Password: "secret"},
+		Headers:        map[string]string{"x-csrf-token": "fetch"},
+	}
+	want := Destination{
+		Name: "basic",
+		Type: HTTPDestination,
+		Properties: map[string]string{
+			URLProperty:                "http://virtual-host:8000",
+			AuthenticationProperty:     BasicAuthentication,
+			ProxyTypeProperty:          OnPremiseProxy,
+			LocationIDProperty:         "berlin",
+			UserProperty:               "alice",
+			PasswordProperty:           "secret",
+			"URL.headers.x-csrf-token": "fetch",
+		},
+	}
+	if got := conf.Destination(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected destination\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestHTTPDestinationConfigWrongType(t *testing.T) {
+	if _, err := NewHTTPDestinationConfig(Destination{Name: "mail", Type: MailDestination}); err == nil {
+		t.Error("expected an error for a MAIL destination")
+	}
+}
+
+func TestTypedConfigRawProperties(t *testing.T) {
+	raw := map[string]json.RawMessage{"Timeout": json.RawMessage(`30`), "Enabled": json.RawMessage(`true`)}
+	tests := []struct {
+		dest      Destination
+		roundTrip func(Destination) (Destination, map[string]json.RawMessage)
+	}{
+		{
+			Destination{Name: "http", Type: HTTPDestination, Properties: map[string]string{URLProperty: "https://example.com"}},
+			func(d Destination) (Destination, map[string]json.RawMessage) {
+				c, _ := NewHTTPDestinationConfig(d)
+				return c.Destination(), c.RawProperties
+			},
+		},
+		{
+			Destination{Name: "mail", Type: MailDestination, Properties: map[string]string{"mail.smtp.host": "smtp.example.com"}},
+			func(d Destination) (Destination, map[string]json.RawMessage) {
+				c, _ := NewMailDestinationConfig(d)
+				return c.Destination(), c.RawProperties
+			},
+		},
+		{
+			Destination{Name: "ldap", Type: LDAPDestination, Properties: map[string]string{"ldap.url": "ldaps://ldap.example.com"}},
+			func(d Destination) (Destination, map[string]json.RawMessage) {
+				c, _ := NewLDAPDestinationConfig(d)
+				return c.Destination(), c.RawProperties
+			},
+		},
+		{
+			Destination{Name: "rfc", Type: RFCDestination, Properties: map[string]string{"jco.client.ashost": "erp"}},
+			func(d Destination) (Destination, map[string]json.RawMessage) {
+				c, _ := NewRFCDestinationConfig(d)
+				return c.Destination(), c.RawProperties
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.dest.Type), func(t *testing.T) {
+			dest := tt.dest
+			dest.RawProperties = raw
+			got, configRaw := tt.roundTrip(dest)
+			if !reflect.DeepEqual(configRaw, raw) {
+				t.Errorf("expected the raw properties in the configuration, got %v", configRaw)
+			}
+			if !reflect.DeepEqual(got, dest) {
+				t.Errorf("round trip changed the destination\n got %+v\nwant %+v", got, dest)
+			}
+			encoded, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(encoded), `"Enabled":true`) || !strings.Contains(string(encoded), `"Timeout":30`) {
+				t.Errorf("expected the raw properties in %s", encoded)
+			}
+		})
+	}
+}
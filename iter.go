@@ -0,0 +1,92 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"iter"
+)
+
+// AllSubaccountDestinations returns an iterator over the destinations posted on subaccount level, fetching the pages
+// selected by opts as the iteration proceeds. Subaccount is determined by the passed OAuth access token.
+// Iteration stops after the first error.
+func (d *DestinationClient) AllSubaccountDestinations(ctx context.Context, opts ListOptions) iter.Seq2[Destination, error] {
+	return d.Destinations(SubaccountScope).All(ctx, opts)
+}
+
+// AllInstanceDestinations returns an iterator over the destinations posted on the service instance level, like AllSubaccountDestinations
+func (d *DestinationClient) AllInstanceDestinations(ctx context.Context, opts ListOptions) iter.Seq2[Destination, error] {
+	return d.Destinations(InstanceScope).All(ctx, opts)
+}
+
+// AllSubaccountCertificates returns an iterator over the certificates posted on the subaccount level, like AllSubaccountDestinations
+func (d *DestinationClient) AllSubaccountCertificates(ctx context.Context, opts ListOptions) iter.Seq2[Certificate, error] {
+	return d.Certificates(SubaccountScope).All(ctx, opts)
+}
+
+// AllInstanceCertificates returns an iterator over the certificates posted on the service instance level, like AllSubaccountDestinations
+func (d *DestinationClient) AllInstanceCertificates(ctx context.Context, opts ListOptions) iter.Seq2[Certificate, error] {
+	return d.Certificates(InstanceScope).All(ctx, opts)
+}
+
+// allItems iterates over the list at path, starting at opts.Page or at the first page. Each page is decoded while it
+// is read, and the response is closed as soon as the caller stops the iteration. Like ListAll, it always requests the
+// page count, which tells where the list ends when the service sends no Link header.
+func allItems[T any](ctx context.Context, d *DestinationClient, path string, opts ListOptions) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		o := opts
+		if o.Page == 0 {
+			o.Page = 1
+		}
+		o.PageCount = true
+		for {
+			next, err := streamPage(ctx, d, path, o, yield)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			if next <= o.Page {
+				return
+			}
+			o.Page = next
+		}
+	}
+}
+
+// streamPage passes the items of a single page to yield. It returns the number of the next page, or zero if
+// this was the last page or yield stopped the iteration.
+func streamPage[T any](ctx context.Context, d *DestinationClient, path string, opts ListOptions, yield func(T, error) bool) (int, error) {
+	response, err := getList(ctx, d, path, opts.queryParams())
+	if err != nil {
+		return 0, err
+	}
+	body := response.RawBody()
+	defer body.Close()
+
+	stopped := false
+	items, err := decodeList(newDecoder(body), func(item T) bool {
+		stopped = !yield(item, nil)
+		return !stopped
+	})
+	if err != nil || stopped || items == 0 {
+		return 0, err
+	}
+	page := Page[T]{Number: opts.Page}
+	page.readHeaders(response.Header())
+	return page.Next, nil
+}
@@ -0,0 +1,133 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAllDestinations(t *testing.T) {
+	for _, withLink := range []bool{true, false} {
+		client, _ := newTestClient(t, pagedDestinations(t, "/subaccountDestinations", 7, withLink))
+		var names []string
+		for dest, err := range client.AllSubaccountDestinations(context.Background(), ListOptions{PageSize: 3}) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, dest.Name)
+		}
+		if len(names) != 7 || names[0] != "dest0" || names[6] != "dest6" {
+			t.Errorf("link header %v: unexpected destinations %v", withLink, names)
+		}
+	}
+}
+
+func TestAllDestinationsRangedTwice(t *testing.T) {
+	client, _ := newTestClient(t, pagedDestinations(t, "/subaccountDestinations", 7, true))
+	all := client.AllSubaccountDestinations(context.Background(), ListOptions{PageSize: 3})
+	for i := 0; i < 2; i++ {
+		var names []string
+		for dest, err := range all {
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, dest.Name)
+		}
+		if len(names) != 7 || names[0] != "dest0" || names[6] != "dest6" {
+			t.Errorf("iteration %d: unexpected destinations %v", i, names)
+		}
+	}
+}
+
+func TestAllCertificatesError(t *testing.T) {
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/instanceCertificates" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"ErrorMessage":"missing scope"}`)
+	}))
+	var errs []error
+	for _, err := range client.AllInstanceCertificates(context.Background(), ListOptions{}) {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrForbidden) || errs[0].Error() != "missing scope" {
+		t.Errorf("expected a single forbidden error, got %v", errs)
+	}
+}
+
+func TestAllDestinationsInvalidBody(t *testing.T) {
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[{"Name":"dest0","Type":"HTTP"},{"Name":`)
+	}))
+	var names []string
+	var lastErr error
+	for dest, err := range client.AllInstanceDestinations(context.Background(), ListOptions{}) {
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		names = append(names, dest.Name)
+	}
+	if len(names) != 1 || lastErr == nil {
+		t.Errorf("expected one destination followed by an error, got %v and %v", names, lastErr)
+	}
+}
+
+func TestAllDestinationsStopsEarly(t *testing.T) {
+	requests := 0
+	closed := make(chan struct{})
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Link", `</subaccountDestinations?$page=2>; rel="next"`)
+		fmt.Fprint(w, `[{"Name":"dest0","Type":"HTTP"},`)
+		w.(http.Flusher).Flush()
+		// The rest of the page is never sent, the client must close the connection instead of waiting for it
+		select {
+		case <-r.Context().Done():
+			close(closed)
+		case <-time.After(5 * time.Second):
+			t.Error("the client did not close the response")
+		}
+	}))
+
+	for dest, err := range client.AllSubaccountDestinations(context.Background(), ListOptions{}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dest.Name != "dest0" {
+			t.Errorf("unexpected destination %q", dest.Name)
+		}
+		break
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the response was not closed")
+	}
+	if requests != 1 {
+		t.Errorf("expected a single request, got %d", requests)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+)
+
+// Values of the ldap.authentication property
+const (
+	LDAPSimpleAuthentication = "simple"
+	LDAPNoAuthentication     = "none"
+)
+
+// LDAPDestinationConfig is the typed configuration of an LDAP destination.
+// The ldap.* properties are copied verbatim into the fields. AdditionalProperties and RawProperties hold everything
+// else on the destination, and Destination writes them back untouched.
+type LDAPDestinationConfig struct {
+	// The name of the destination
+	Name string
+	// ldap.description property
+	Description string
+	// ldap.url property, an ldap:// or ldaps:// URL
+	URL string
+	// ldap.proxyType property, InternetProxy or OnPremiseProxy
+	ProxyType string
+	// LocationID property, selecting the Cloud Connector of OnPremise destinations
+	LocationID string
+	// ldap.authentication property, LDAPSimpleAuthentication or LDAPNoAuthentication
+	Authentication string
+	// ldap.user property, the bind DN
+	User string
+	// ldap.password property
+	Password string
+
+	// Properties that have no field
+	AdditionalProperties map[string]string
+	// Properties whose values are not JSON strings, from Destination.RawProperties
+	RawProperties map[string]json.RawMessage
+}
+
+func (c *LDAPDestinationConfig) properties() []configProperty {
+	return []configProperty{
+		{"ldap.description", &c.Description},
+		{"ldap.url", &c.URL},
+		{"ldap.proxyType", &c.ProxyType},
+		{LocationIDProperty, &c.LocationID},
+		{"ldap.authentication", &c.Authentication},
+		{"ldap.user", &c.User},
+		{"ldap.password", &c.Password},
+	}
+}
+
+// NewLDAPDestinationConfig converts a destination of type LDAP to its typed configuration
+func NewLDAPDestinationConfig(d Destination) (LDAPDestinationConfig, error) {
+	if d.Type != LDAPDestination {
+		return LDAPDestinationConfig{}, fmt.Errorf("destination %q has type %q, expected %q", d.Name, d.Type, LDAPDestination)
+	}
+	c := LDAPDestinationConfig{Name: d.Name, RawProperties: maps.Clone(d.RawProperties)}
+	c.AdditionalProperties = readConfigProperties(d.Properties, c.properties())
+	return c, nil
+}
+
+// Destination converts the typed configuration to a Destination of type LDAP
+func (c LDAPDestinationConfig) Destination() Destination {
+	return Destination{
+		Name:          c.Name,
+		Type:          LDAPDestination,
+		Properties:    writeConfigProperties(c.AdditionalProperties, c.properties()),
+		RawProperties: maps.Clone(c.RawProperties),
+	}
+}
+
+// Validate checks that the configuration describes a reachable LDAP server. The returned error wraps
+// ErrInvalidDestination and a ValidationError for every problem found.
+func (c LDAPDestinationConfig) Validate() error {
+	var v validationErrors
+	c.validate(&v)
+	return v.err(c.Name)
+}
+
+func (c LDAPDestinationConfig) validate(v *validationErrors) {
+	if c.URL == "" {
+		v.require("ldap.url", c.URL)
+	} else if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+		v.add("ldap.url", "ldap.url %q must be an ldap:// or ldaps:// URL", c.URL)
+	}
+	switch c.ProxyType {
+	case "", InternetProxy, OnPremiseProxy:
+	default:
+		v.add("ldap.proxyType", "ldap.proxyType %q is not supported", c.ProxyType)
+	}
+	switch c.Authentication {
+	case "", LDAPNoAuthentication:
+	case LDAPSimpleAuthentication:
+		if c.User == "" {
+			v.add("ldap.user", "ldap.user is required for %s authentication", LDAPSimpleAuthentication)
+		}
+		if c.Password == "" {
+			v.add("ldap.password", "ldap.password is required for %s authentication", LDAPSimpleAuthentication)
+		}
+	default:
+		v.add("ldap.authentication", "ldap.authentication %q is not supported", c.Authentication)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLDAPDestinationConfig(t *testing.T) {
+	dest := Destination{
+		Name: "directory",
+		Type: LDAPDestination,
+		Properties: map[string]string{
+			"ldap.description":    "Corporate directory",
+			"ldap.url":            "ldaps://ldap.corp.local:636",
+			"ldap.proxyType":      OnPremiseProxy,
+			LocationIDProperty:    "berlin",
+			"ldap.authentication": LDAPSimpleAuthentication,
+			"ldap.user":           "cn=reader,dc=corp,dc=local",
+			"ldap.password":       "secret",
+			"ldap.referrals":      "follow",
+		},
+	}
+	conf, err := NewLDAPDestinationConfig(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := LDAPDestinationConfig{
+		Name:                 "directory",
+		Description:          "Corporate directory",
+		URL:                  "ldaps://ldap.corp.local:636",
+		ProxyType:            OnPremiseProxy,
+		LocationID:           "berlin",
+		Authentication:       LDAPSimpleAuthentication,
+		User:                 "cn=reader,dc=corp,dc=local",
+		Password:             "secret",
+		AdditionalProperties: map[string]string{"ldap.referrals": "follow"},
+	}
+	if !reflect.DeepEqual(conf, want) {
+		t.Errorf("unexpected configuration\n got %+v\nwant %+v", conf, want)
+	}
+	if got := conf.Destination(); !reflect.DeepEqual(got, dest) {
+		t.Errorf("round trip changed the destination\n got %+v\nwant %+v", got, dest)
+	}
+}
+
+func TestLDAPDestinationConfigValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties map[string]string
+		want       []string
+	}{
+		{"missing url", map[string]string{}, []string{"ldap.url is required"}},
+		{"http url", map[string]string{"ldap.url": "http://ldap.corp.local"}, []string{"ldap:// or ldaps://"}},
+		{"simple without credentials", map[string]string{"ldap.url": "ldap://h", "ldap.authentication": LDAPSimpleAuthentication}, []string{"ldap.user is required", "ldap.password is required"}},
+		{"authentication", map[string]string{"ldap.url": "ldap://h", "ldap.authentication": "sasl"}, []string{"ldap.authentication"}},
+		{"proxy type", map[string]string{"ldap.url": "ldap://h", "ldap.proxyType": "Private"}, []string{"ldap.proxyType"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := NewLDAPDestinationConfig(Destination{Name: "directory", Type: LDAPDestination, Properties: tt.properties})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = conf.Validate()
+			if err == nil {
+				t.Fatal("expected a validation error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected %q in %q", want, err)
+				}
+			}
+		})
+	}
+	if _, err := NewLDAPDestinationConfig(Destination{Name: "rfc", Type: RFCDestination}); err == nil {
+		t.Error("expected an error for an RFC destination")
+	}
+}
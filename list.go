@@ -0,0 +1,178 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ListOptions contains the optional values passed to the service when listing destinations or certificates
+type ListOptions struct {
+	// Page is passed as $page, the number of the page to return starting at 1. If zero, the list is not paginated
+	Page int
+	// PageSize is passed as $pageSize, the number of items per page. If zero, the service default is used
+	PageSize int
+	// PageCount is passed as $pageCount, asking the service for the total number of pages and items in the Page-Count
+	// and Item-Count headers. Always set by ListAll
+	PageCount bool
+	// Filter is passed as $filter, e.g. as returned by NameFilter
+	Filter string
+	// Select is passed as $select, limiting the returned properties to the listed ones
+	Select []string
+}
+
+// NameFilter returns a Filter matching the destinations or certificates with any of the given names
+func NameFilter(names ...string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = "'" + strings.ReplaceAll(name, "'", "''") + "'"
+	}
+	return "Name in (" + strings.Join(quoted, ",") + ")"
+}
+
+// queryParams returns the query parameters for the options
+func (o ListOptions) queryParams() map[string]string {
+	params := map[string]string{}
+	if o.Page > 0 {
+		params["$page"] = strconv.Itoa(o.Page)
+	}
+	if o.PageSize > 0 {
+		params["$pageSize"] = strconv.Itoa(o.PageSize)
+	}
+	if o.PageCount {
+		params["$pageCount"] = "true"
+	}
+	if o.Filter != "" {
+		params["$filter"] = o.Filter
+	}
+	if len(o.Select) > 0 {
+		params["$select"] = strings.Join(o.Select, ",")
+	}
+	return params
+}
+
+// Page is a single page of a list returned by the service
+type Page[T any] struct {
+	// The items on the page
+	Items []T
+	// The number of the page, or zero if the list is not paginated
+	Number int
+	// Total number of pages, from the Page-Count header. Zero unless requested with ListOptions.PageCount
+	Count int
+	// Total number of items on all the pages, from the Item-Count header. Zero unless requested with ListOptions.PageCount
+	ItemCount int
+	// The number of the next page, from the Link header. Zero on the last page
+	Next int
+}
+
+// ListSubaccountDestinations returns a page of the destinations posted on subaccount level. Subaccount is determined by the passed OAuth access token.
+func (d *DestinationClient) ListSubaccountDestinations(ctx context.Context, opts ListOptions) (Page[Destination], error) {
+	return d.Destinations(SubaccountScope).ListPage(ctx, opts)
+}
+
+// ListInstanceDestinations returns a page of the destinations posted on the service instance level. The service instance and subaccount are determined by the passed OAuth access token
+func (d *DestinationClient) ListInstanceDestinations(ctx context.Context, opts ListOptions) (Page[Destination], error) {
+	return d.Destinations(InstanceScope).ListPage(ctx, opts)
+}
+
+// ListSubaccountCertificates returns a page of the certificates posted on the subaccount level. The Subaccount is determined based on the passed OAuth access token
+func (d *DestinationClient) ListSubaccountCertificates(ctx context.Context, opts ListOptions) (Page[Certificate], error) {
+	return d.Certificates(SubaccountScope).ListPage(ctx, opts)
+}
+
+// ListInstanceCertificates returns a page of the certificates posted on the service instance level. The service instance and subaccount are determined by the passed OAuth access token
+func (d *DestinationClient) ListInstanceCertificates(ctx context.Context, opts ListOptions) (Page[Certificate], error) {
+	return d.Certificates(InstanceScope).ListPage(ctx, opts)
+}
+
+// ListAll calls list for consecutive pages, starting at opts.Page or at the first page, and returns the items of all the pages.
+// The page count is always requested, so the last page is known even if the service sends no Link header. For example:
+//
+//	destinations, err := ListAll(ctx, client.ListSubaccountDestinations, ListOptions{PageSize: 100})
+func ListAll[T any](ctx context.Context, list func(context.Context, ListOptions) (Page[T], error), opts ListOptions) ([]T, error) {
+	if opts.Page == 0 {
+		opts.Page = 1
+	}
+	opts.PageCount = true
+	items := make([]T, 0)
+	for {
+		page, err := list(ctx, opts)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, page.Items...)
+		if page.Next <= opts.Page || len(page.Items) == 0 {
+			return items, nil
+		}
+		opts.Page = page.Next
+	}
+}
+
+// listPage retrieves a page of the list at path
+func listPage[T any](ctx context.Context, d *DestinationClient, path string, opts ListOptions) (Page[T], error) {
+
+	retval := Page[T]{Items: make([]T, 0), Number: opts.Page}
+
+	response, err := getList(ctx, d, path, opts.queryParams())
+	if err != nil {
+		return retval, err
+	}
+	if retval.Items, err = readList[T](response); err != nil {
+		return retval, err
+	}
+	retval.readHeaders(response.Header())
+	return retval, nil
+}
+
+// readHeaders sets the page and item counts and the number of the next page from the Page-Count, Item-Count and
+// Link headers of a list response
+func (p *Page[T]) readHeaders(header http.Header) {
+	if value := header.Get("Page-Count"); value != "" {
+		p.Count, _ = strconv.Atoi(value)
+	}
+	if value := header.Get("Item-Count"); value != "" {
+		p.ItemCount, _ = strconv.Atoi(value)
+	}
+	p.Next = nextPage(header.Values("Link"))
+	if p.Next == 0 && p.Number > 0 && p.Number < p.Count {
+		p.Next = p.Number + 1
+	}
+}
+
+// nextPage returns the $page parameter of the rel="next" link in Link header values, or zero if there is none
+func nextPage(links []string) int {
+	for _, value := range links {
+		for _, link := range strings.Split(value, ",") {
+			target, params, _ := strings.Cut(strings.TrimSpace(link), ";")
+			if !strings.Contains(strings.ReplaceAll(params, " ", ""), `rel="next"`) {
+				continue
+			}
+			u, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+			if err != nil {
+				continue
+			}
+			if page, err := strconv.Atoi(u.Query().Get("$page")); err == nil {
+				return page
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,157 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestListOptions(t *testing.T) {
+	var query map[string]string
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = map[string]string{}
+		for k := range r.URL.Query() {
+			query[k] = r.URL.Query().Get(k)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[]`)
+	}))
+
+	_, err := client.ListSubaccountDestinations(context.Background(), ListOptions{
+		Page:      2,
+		PageSize:  50,
+		PageCount: true,
+		Filter:    NameFilter("dest1", "o'brien"),
+		Select:    []string{"Name", "Type"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"$page":      "2",
+		"$pageSize":  "50",
+		"$pageCount": "true",
+		"$filter":    "Name in ('dest1','o''brien')",
+		"$select":    "Name,Type",
+	}
+	if !reflect.DeepEqual(query, want) {
+		t.Errorf("got query %v, want %v", query, want)
+	}
+
+	if _, err := client.ListInstanceCertificates(context.Background(), ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(query) != 0 {
+		t.Errorf("expected no query parameters, got %v", query)
+	}
+}
+
+// pagedDestinations serves total destinations on the given path, in pages of the requested size
+func pagedDestinations(t *testing.T, path string, total int, withLink bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("$page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("$pageSize"))
+		pages := (total + size - 1) / size
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("$pageCount") == "true" {
+			w.Header().Set("Page-Count", strconv.Itoa(pages))
+			w.Header().Set("Item-Count", strconv.Itoa(total))
+		}
+		if withLink {
+			link := fmt.Sprintf(`<%s?$page=1&$pageSize=%d>; rel="first"`, path, size)
+			if page < pages {
+				link += fmt.Sprintf(`, <%s?$page=%d&$pageSize=%d>; rel="next"`, path, page+1, size)
+			}
+			w.Header().Set("Link", link)
+		}
+		fmt.Fprint(w, "[")
+		for i := (page - 1) * size; i < min(page*size, total); i++ {
+			if i > (page-1)*size {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintf(w, `{"Name":"dest%d","Type":"HTTP"}`, i)
+		}
+		fmt.Fprint(w, "]")
+	})
+}
+
+func TestListPage(t *testing.T) {
+	client, _ := newTestClient(t, pagedDestinations(t, "/instanceDestinations", 5, true))
+	page, err := client.ListInstanceDestinations(context.Background(), ListOptions{Page: 2, PageSize: 2, PageCount: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 2 || page.Items[0].Name != "dest2" || page.Number != 2 || page.Count != 3 || page.ItemCount != 5 || page.Next != 3 {
+		t.Errorf("unexpected page %+v", page)
+	}
+	page, err = client.ListInstanceDestinations(context.Background(), ListOptions{Page: 3, PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.Count != 0 || page.ItemCount != 0 || page.Next != 0 {
+		t.Errorf("unexpected last page %+v", page)
+	}
+}
+
+func TestListAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		withLink bool
+	}{
+		{"link header", true},
+		{"page count", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newTestClient(t, pagedDestinations(t, "/subaccountDestinations", 7, tt.withLink))
+			destinations, err := ListAll(context.Background(), client.ListSubaccountDestinations, ListOptions{PageSize: 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(destinations) != 7 {
+				t.Fatalf("expected 7 destinations, got %d", len(destinations))
+			}
+			for i, dest := range destinations {
+				if dest.Name != fmt.Sprintf("dest%d", i) {
+					t.Errorf("unexpected destination %d: %s", i, dest.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestListError(t *testing.T) {
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"ErrorMessage":"invalid $filter"}`)
+	}))
+	_, err := ListAll(context.Background(), client.ListSubaccountCertificates, ListOptions{Filter: "bad"})
+	var errMessage ErrorMessage
+	if !errors.As(err, &errMessage) || errMessage.StatusCode() != http.StatusBadRequest {
+		t.Errorf("expected a 400 ErrorMessage, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnmarshalJSON unmarshalls an AuthToken, accepting expires_in both as a number and as a string
+func (t *AuthToken) UnmarshalJSON(b []byte) error {
+	type authToken AuthToken
+	var unmarshalled struct {
+		authToken
+		ExpiresIn json.RawMessage `json:"expires_in"`
+	}
+	if err := json.Unmarshal(b, &unmarshalled); err != nil {
+		return err
+	}
+	*t = AuthToken(unmarshalled.authToken)
+	expiresIn := strings.Trim(string(unmarshalled.ExpiresIn), `"`)
+	if expiresIn != "" && expiresIn != "null" {
+		seconds, err := strconv.Atoi(expiresIn)
+		if err != nil {
+			return err
+		}
+		t.ExpiresIn = seconds
+	}
+	return nil
+}
+
+// Expired reports whether the token expires before now
+func (t AuthToken) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+// Header returns the name and value of the HTTP header carrying the token
+func (t AuthToken) Header() (string, string) {
+	if t.HTTPHeader.Key != "" {
+		return t.HTTPHeader.Key, t.HTTPHeader.Value
+	}
+	return "Authorization", t.Type + " " + t.Value
+}
+
+// setExpiry computes the expiry time of the tokens, based on the time of the lookup
+func (r *DestinationLookupResult) setExpiry(lookupTime time.Time) {
+	for i, token := range r.AuthTokens {
+		if token.ExpiresIn > 0 {
+			r.AuthTokens[i].ExpiresAt = lookupTime.Add(time.Duration(token.ExpiresIn) * time.Second)
+		}
+	}
+}
+
+// ExpiresAt returns the earliest expiry time of the authentication tokens, or the zero time if none of them expire
+func (r DestinationLookupResult) ExpiresAt() time.Time {
+	var earliest time.Time
+	for _, token := range r.AuthTokens {
+		if !token.ExpiresAt.IsZero() && (earliest.IsZero() || token.ExpiresAt.Before(earliest)) {
+			earliest = token.ExpiresAt
+		}
+	}
+	return earliest
+}
+
+// TokenError returns an *AuthTokenError if the service failed to retrieve any of the authentication tokens, or if no
+// tokens were returned for a destination whose authentication type requires them. It returns nil otherwise.
+func (r DestinationLookupResult) TokenError() error {
+	var errs []string
+	for _, token := range r.AuthTokens {
+		if token.Error != "" {
+			errs = append(errs, token.Error)
+		}
+	}
+	if len(errs) > 0 || (len(r.AuthTokens) == 0 && requiresAuthTokens(r.Destination.Properties[AuthenticationProperty])) {
+		return &AuthTokenError{
+			Destination: r.Destination.Name,
+			Errors:      errs,
+		}
+	}
+	return nil
+}
+
+// Tokens returns the authentication tokens, or the error returned by TokenError
+func (r DestinationLookupResult) Tokens() ([]AuthToken, error) {
+	if err := r.TokenError(); err != nil {
+		return nil, err
+	}
+	return r.AuthTokens, nil
+}
+
+// requiresAuthTokens reports whether the service returns authentication tokens for the authentication type
+func requiresAuthTokens(authentication string) bool {
+	switch authentication {
+	case BasicAuthentication, SAPAssetionSSOAuthentication, SAMLAssertionAuthentication:
+		return true
+	}
+	return strings.HasPrefix(authentication, "OAuth2")
+}
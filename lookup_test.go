@@ -0,0 +1,98 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFindAuthTokens(t *testing.T) {
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"owner": {"SubaccountId": "sub"},
+			"destinationConfiguration": {"Name": "dest1", "Type": "HTTP", "Authentication": "OAuth2ClientCredentials"},
+			"authTokens": [{
+				"type": "bearer",
+				"value": "token-value",
+				"http_header": {"key": "Authorization", "value": "Bearer token-value"},
+				"expires_in": "3600",
+				"scope": "read write"
+			}]
+		}`)
+	}))
+
+	before := time.Now()
+	result, err := client.Find("dest1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := result.Tokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := tokens[0]
+	if token.ExpiresIn != 3600 || token.Scope != "read write" {
+		t.Errorf("unexpected token %#v", token)
+	}
+	if token.ExpiresAt.Before(before.Add(time.Hour)) || token.ExpiresAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("unexpected expiry %v", token.ExpiresAt)
+	}
+	if key, value := token.Header(); key != "Authorization" || value != "Bearer token-value" {
+		t.Errorf("unexpected header %s: %s", key, value)
+	}
+	if result.ExpiresAt() != token.ExpiresAt || token.Expired(time.Now()) || !token.Expired(token.ExpiresAt) {
+		t.Error("unexpected expiry state")
+	}
+}
+
+func TestTokenError(t *testing.T) {
+	tests := []struct {
+		name   string
+		result DestinationLookupResult
+		errors []string
+	}{
+		{"token failure", DestinationLookupResult{
+			Destination: Destination{Name: "dest1", Properties: map[string]string{AuthenticationProperty: OAuth2UserTokenExchangeAuthentication}},
+			AuthTokens:  []AuthToken{{Type: "", Value: "", Error: "Retrieval of OAuth token failed"}},
+		}, []string{"Retrieval of OAuth token failed"}},
+		{"no tokens", DestinationLookupResult{
+			Destination: Destination{Name: "dest1", Properties: map[string]string{AuthenticationProperty: BasicAuthentication}},
+		}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.result.Tokens()
+			if !errors.Is(err, ErrTokenRetrieval) {
+				t.Fatalf("expected ErrTokenRetrieval, got %v", err)
+			}
+			var tokenErr *AuthTokenError
+			if !errors.As(err, &tokenErr) || tokenErr.Destination != "dest1" || len(tokenErr.Errors) != len(tt.errors) {
+				t.Errorf("unexpected error %#v", err)
+			}
+		})
+	}
+
+	noAuth := DestinationLookupResult{Destination: Destination{Properties: map[string]string{AuthenticationProperty: NoAuthentication}}}
+	if err := noAuth.TokenError(); err != nil {
+		t.Errorf("unexpected error for NoAuthentication: %v", err)
+	}
+}
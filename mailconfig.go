@@ -0,0 +1,350 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// Values of the mail.transport.protocol property
+const (
+	SMTPProtocol  = "smtp"
+	SMTPSProtocol = "smtps"
+)
+
+// MailDestinationConfig is the typed configuration of a MAIL destination.
+// Each field holds the string of its mail property as is; flags such as StartTLS are not parsed. Unmapped properties
+// stay in AdditionalProperties and non-string values in RawProperties, so a MAIL destination survives the conversion
+// to a MailDestinationConfig and back.
+type MailDestinationConfig struct {
+	// The name of the destination
+	Name string
+	// Description property
+	Description string
+	// Authentication property, BasicAuthentication or NoAuthentication
+	Authentication string
+	// ProxyType property, InternetProxy or OnPremiseProxy
+	ProxyType string
+	// LocationID property, selecting the Cloud Connector of OnPremise destinations
+	LocationID string
+
+	// mail.transport.protocol property, SMTPProtocol or SMTPSProtocol
+	Protocol string
+	// mail.smtp.host property
+	Host string
+	// mail.smtp.port property
+	Port string
+	// mail.user property
+	User string
+	// mail.password property
+	Password string
+	// mail.smtp.from property, the default sender address
+	From string
+	// mail.smtp.starttls.enable property, "true" or "false"
+	StartTLS string
+	// mail.smtp.starttls.required property, "true" or "false"
+	StartTLSRequired string
+	// mail.smtp.ssl.enable property, "true" or "false". Enables implicit TLS like the smtps protocol
+	SSL string
+
+	// Properties that have no field
+	AdditionalProperties map[string]string
+	// Properties whose values are not JSON strings, from Destination.RawProperties
+	RawProperties map[string]json.RawMessage
+}
+
+func (c *MailDestinationConfig) properties() []configProperty {
+	return []configProperty{
+		{DescriptionProperty, &c.Description},
+		{AuthenticationProperty, &c.Authentication},
+		{ProxyTypeProperty, &c.ProxyType},
+		{LocationIDProperty, &c.LocationID},
+		{"mail.transport.protocol", &c.Protocol},
+		{"mail.smtp.host", &c.Host},
+		{"mail.smtp.port", &c.Port},
+		{"mail.user", &c.User},
+		{"mail.password", &c.Password},
+		{"mail.smtp.from", &c.From},
+		{"mail.smtp.starttls.enable", &c.StartTLS},
+		{"mail.smtp.starttls.required", &c.StartTLSRequired},
+		{"mail.smtp.ssl.enable", &c.SSL},
+	}
+}
+
+// NewMailDestinationConfig converts a destination of type MAIL to its typed configuration
+func NewMailDestinationConfig(d Destination) (MailDestinationConfig, error) {
+	if d.Type != MailDestination {
+		return MailDestinationConfig{}, fmt.Errorf("destination %q has type %q, expected %q", d.Name, d.Type, MailDestination)
+	}
+	c := MailDestinationConfig{Name: d.Name, RawProperties: maps.Clone(d.RawProperties)}
+	c.AdditionalProperties = readConfigProperties(d.Properties, c.properties())
+	return c, nil
+}
+
+// Destination converts the typed configuration to a Destination of type MAIL
+func (c MailDestinationConfig) Destination() Destination {
+	return Destination{
+		Name:          c.Name,
+		Type:          MailDestination,
+		Properties:    writeConfigProperties(c.AdditionalProperties, c.properties()),
+		RawProperties: maps.Clone(c.RawProperties),
+	}
+}
+
+// Validate checks that the configuration describes a reachable SMTP server. The returned error wraps
+// ErrInvalidDestination and a ValidationError for every problem found.
+func (c MailDestinationConfig) Validate() error {
+	var v validationErrors
+	c.validate(&v)
+	return v.err(c.Name)
+}
+
+func (c MailDestinationConfig) validate(v *validationErrors) {
+	v.require("mail.smtp.host", c.Host)
+	if c.Port != "" && !isPort(c.Port) {
+		v.add("mail.smtp.port", "mail.smtp.port %q is not a valid port", c.Port)
+	}
+	switch c.Protocol {
+	case "", SMTPProtocol, SMTPSProtocol:
+	default:
+		v.add("mail.transport.protocol", "mail.transport.protocol %q is not supported", c.Protocol)
+	}
+	switch c.Authentication {
+	case "", NoAuthentication:
+	case BasicAuthentication:
+		if c.User == "" {
+			v.add("mail.user", "mail.user is required for %s", BasicAuthentication)
+		}
+	default:
+		v.add(AuthenticationProperty, "authentication %q is not supported for %s destinations", c.Authentication, MailDestination)
+	}
+	for _, flag := range []configProperty{
+		{"mail.smtp.starttls.enable", &c.StartTLS},
+		{"mail.smtp.starttls.required", &c.StartTLSRequired},
+		{"mail.smtp.ssl.enable", &c.SSL},
+	} {
+		if _, err := parseFlag(*flag.value); err != nil {
+			v.add(flag.name, "%s %q is not a boolean", flag.name, *flag.value)
+		}
+	}
+}
+
+// parseFlag parses a boolean property, treating an empty value as false
+func parseFlag(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// implicitTLS reports whether the connection is encrypted from the start, rather than upgraded with STARTTLS
+func (c MailDestinationConfig) implicitTLS() bool {
+	ssl, _ := parseFlag(c.SSL)
+	return c.Protocol == SMTPSProtocol || ssl
+}
+
+// address returns the address of the SMTP server, using the default port of the protocol if none is configured
+func (c MailDestinationConfig) address() string {
+	port := c.Port
+	if port == "" {
+		port = "25"
+		if c.implicitTLS() {
+			port = "465"
+		}
+	}
+	return net.JoinHostPort(c.Host, port)
+}
+
+// ContextDialer dials connections with a context. Implemented by net.Dialer and SOCKS5Dialer
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// SMTPOption configures how SMTPClient connects to the mail server
+type SMTPOption func(*smtpOptions)
+
+type smtpOptions struct {
+	dialer    ContextDialer
+	tlsConfig *tls.Config
+}
+
+// WithSMTPDialer connects to the mail server with dialer. Use the SOCKS5Dialer of a ConnectivityProxy for OnPremise destinations
+func WithSMTPDialer(dialer ContextDialer) SMTPOption {
+	return func(o *smtpOptions) {
+		o.dialer = dialer
+	}
+}
+
+// WithSMTPTLSConfig sets the TLS configuration used for implicit TLS and STARTTLS. The server name defaults to the mail host
+func WithSMTPTLSConfig(conf *tls.Config) SMTPOption {
+	return func(o *smtpOptions) {
+		o.tlsConfig = conf
+	}
+}
+
+// SMTPClient connects to the mail server of the destination, encrypting the connection with implicit TLS or STARTTLS
+// as configured, and authenticates when the destination uses BasicAuthentication. The ctx applies to connecting,
+// the handshake and authentication; the caller is responsible for closing the client.
+func (c MailDestinationConfig) SMTPClient(ctx context.Context, opts ...SMTPOption) (*smtp.Client, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	options := smtpOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.dialer == nil {
+		if c.ProxyType == OnPremiseProxy {
+			return nil, fmt.Errorf("destination %q is an OnPremise destination and requires a SOCKS5 dialer", c.Name)
+		}
+		options.dialer = &net.Dialer{}
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if options.tlsConfig != nil {
+		tlsConfig = options.tlsConfig.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = c.Host
+	}
+
+	conn, err := options.dialer.DialContext(ctx, "tcp", c.address())
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	client, err := c.setupSMTP(ctx, conn, tlsConfig)
+	if !stop() {
+		err = errors.Join(err, ctx.Err())
+	}
+	if err != nil {
+		if client != nil {
+			client.Close()
+		} else {
+			conn.Close()
+		}
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return client, nil
+}
+
+// setupSMTP performs the TLS handshake, STARTTLS and authentication on an open connection
+func (c MailDestinationConfig) setupSMTP(ctx context.Context, conn net.Conn, tlsConfig *tls.Config) (*smtp.Client, error) {
+	if c.implicitTLS() {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, fmt.Errorf("smtp: TLS handshake: %w", err)
+		}
+		conn = tlsConn
+	}
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		return nil, err
+	}
+	if !c.implicitTLS() {
+		startTLS, _ := parseFlag(c.StartTLS)
+		required, _ := parseFlag(c.StartTLSRequired)
+		supported, _ := client.Extension("STARTTLS")
+		switch {
+		case (startTLS || required) && supported:
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return client, fmt.Errorf("smtp: STARTTLS: %w", err)
+			}
+		case required:
+			return client, fmt.Errorf("smtp: server %s does not support STARTTLS", c.address())
+		}
+	}
+	if c.Authentication == BasicAuthentication {
+		auth := smtp.PlainAuth("", c.User, c.Password, c.Host)
+		if c.ProxyType == OnPremiseProxy {
+			auth = tunnelPlainAuth{username: c.User, password: c.Password, host: c.Host}
+		}
+		if err := client.Auth(auth); err != nil {
+			return client, fmt.Errorf("smtp: authentication: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// tunnelPlainAuth is the PLAIN mechanism for mail servers reached through the Cloud Connector. The tunnel is the
+// secure channel, so unlike smtp.PlainAuth it also sends the credentials over connections without TLS
+type tunnelPlainAuth struct {
+	username, password, host string
+}
+
+// Start implements smtp.Auth
+func (a tunnelPlainAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if server.Name != a.host {
+		return "", nil, errors.New("smtp: wrong host name")
+	}
+	return "PLAIN", []byte("\x00" + a.username + "\x00" + a.password), nil
+}
+
+// Next implements smtp.Auth
+func (a tunnelPlainAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return nil, errors.New("smtp: unexpected server challenge")
+	}
+	return nil, nil
+}
+
+// SendMail sends msg to the recipients through the mail server of the destination. The sender defaults to the
+// mail.smtp.from property when from is empty. The ctx applies to connecting, as in SMTPClient.
+func (c MailDestinationConfig) SendMail(ctx context.Context, from string, to []string, msg []byte, opts ...SMTPOption) error {
+	if from == "" {
+		from = c.From
+	}
+	client, err := c.SMTPClient(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
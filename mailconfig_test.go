@@ -0,0 +1,310 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMailDestinationConfig(t *testing.T) {
+	dest := Destination{
+		Name: "mail",
+		Type: MailDestination,
+		Properties: map[string]string{
+			AuthenticationProperty:        BasicAuthentication,
+			ProxyTypeProperty:             InternetProxy,
+			"mail.transport.protocol":     SMTPProtocol,
+			"mail.smtp.host":              "smtp.example.com",
+			"mail.smtp.port":              "587",
+			"mail.user":                   "alice",
+			"mail.password":               "secret",
+			"mail.smtp.from":              "noreply@example.com",
+			"mail.smtp.starttls.enable":   "true",
+			"mail.smtp.starttls.required": "true",
+			"mail.smtp.connectiontimeout": "10000",
+		},
+	}
+	conf, err := NewMailDestinationConfig(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := MailDestinationConfig{
+		Name:                 "mail",
+		Authentication:       BasicAuthentication,
+		ProxyType:            InternetProxy,
+		Protocol:             SMTPProtocol,
+		Host:                 "smtp.example.com",
+		Port:                 "587",
+		User:                 "alice",
+		Password:             "secret",
+		From:                 "noreply@example.com",
+		StartTLS:             "true",
+		StartTLSRequired:     "true",
+		AdditionalProperties: map[string]string{"mail.smtp.connectiontimeout": "10000"},
+	}
+	if !reflect.DeepEqual(conf, want) {
+		t.Errorf("unexpected configuration\n got %+v\nwant %+v", conf, want)
+	}
+	if got := conf.Destination(); !reflect.DeepEqual(got, dest) {
+		t.Errorf("round trip changed the destination\n got %+v\nwant %+v", got, dest)
+	}
+}
+
+func TestMailDestinationConfigValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties map[string]string
+		want       []string
+	}{
+		{"missing host", map[string]string{}, []string{"mail.smtp.host is required"}},
+		{"invalid port", map[string]string{"mail.smtp.host": "h", "mail.smtp.port": "smtp"}, []string{"mail.smtp.port"}},
+		{"protocol", map[string]string{"mail.smtp.host": "h", "mail.transport.protocol": "imap"}, []string{"mail.transport.protocol"}},
+		{"basic without user", map[string]string{"mail.smtp.host": "h", AuthenticationProperty: BasicAuthentication}, []string{"mail.user is required"}},
+		{"flags", map[string]string{"mail.smtp.host": "h", "mail.smtp.starttls.enable": "yes please"}, []string{"mail.smtp.starttls.enable"}},
+		{"authentication", map[string]string{"mail.smtp.host": "h", AuthenticationProperty: OAuth2ClientCredentialsAuthentication}, []string{"not supported"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := NewMailDestinationConfig(Destination{Name: "mail", Type: MailDestination, Properties: tt.properties})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = conf.Validate()
+			if err == nil {
+				t.Fatal("expected a validation error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected %q in %q", want, err)
+				}
+			}
+		})
+	}
+	if _, err := NewMailDestinationConfig(Destination{Name: "http", Type: HTTPDestination}); err == nil {
+		t.Error("expected an error for an HTTP destination")
+	}
+}
+
+// localhostCertificate returns the certificate of the httptest TLS server, valid for 127.0.0.1, and a pool trusting it
+func localhostCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	return server.TLS.Certificates[0], pool
+}
+
+// fakeSMTPServer serves a single SMTP session, recording the commands it receives. With a tlsConfig the server
+// offers STARTTLS, or speaks TLS from the start if implicit is set.
+type fakeSMTPServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	implicit  bool
+	commands  chan string
+}
+
+func newFakeSMTPServer(t *testing.T, tlsConfig *tls.Config, implicit bool) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if implicit {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	s := &fakeSMTPServer{listener: listener, tlsConfig: tlsConfig, implicit: implicit, commands: make(chan string, 100)}
+	t.Cleanup(func() { listener.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTPServer) port() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return port
+}
+
+func (s *fakeSMTPServer) serve() {
+	defer close(s.commands)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 fake ESMTP\r\n")
+	secure := s.implicit
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.commands <- line
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO":
+			if s.tlsConfig != nil && !secure {
+				fmt.Fprint(conn, "250-fake\r\n250-STARTTLS\r\n250 AUTH PLAIN\r\n")
+			} else {
+				fmt.Fprint(conn, "250-fake\r\n250 AUTH PLAIN\r\n")
+			}
+		case "STARTTLS":
+			fmt.Fprint(conn, "220 ready\r\n")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, r, secure = tlsConn, bufio.NewReader(tlsConn), true
+			s.commands <- "<tls>"
+		case "AUTH":
+			fmt.Fprint(conn, "235 authenticated\r\n")
+		case "MAIL", "RCPT":
+			fmt.Fprint(conn, "250 OK\r\n")
+		case "DATA":
+			fmt.Fprint(conn, "354 go ahead\r\n")
+			for {
+				data, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if data == ".\r\n" {
+					break
+				}
+				s.commands <- "data: " + strings.TrimRight(data, "\r\n")
+			}
+			fmt.Fprint(conn, "250 queued\r\n")
+		case "QUIT":
+			fmt.Fprint(conn, "221 bye\r\n")
+			return
+		default:
+			fmt.Fprint(conn, "502 not implemented\r\n")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) received() []string {
+	var commands []string
+	for command := range s.commands {
+		commands = append(commands, command)
+	}
+	return commands
+}
+
+func TestSendMail(t *testing.T) {
+	cert, pool := localhostCertificate(t)
+	plain := base64.StdEncoding.EncodeToString([]byte("\x00alice\x00secret"))
+
+	tests := []struct {
+		name      string
+		tlsConfig *tls.Config
+		implicit  bool
+		conf      MailDestinationConfig
+		want      []string
+	}{
+		{
+			name: "plain",
+			conf: MailDestinationConfig{From: "noreply@example.com"},
+			want: []string{"EHLO localhost", "MAIL FROM:<noreply@example.com>", "RCPT TO:<bob@example.com>", "DATA", "data: Subject: hi", "data: ", "data: hello", "QUIT"},
+		},
+		{
+			name:      "starttls",
+			tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+			conf:      MailDestinationConfig{StartTLS: "true", Authentication: BasicAuthentication, User: "alice", Password: "secret", From: "noreply@example.com"},
+			want:      []string{"EHLO localhost", "STARTTLS", "<tls>", "EHLO localhost", "AUTH PLAIN " + plain, "MAIL FROM:<noreply@example.com>", "RCPT TO:<bob@example.com>", "DATA", "data: Subject: hi", "data: ", "data: hello", "QUIT"},
+		},
+		{
+			name:      "implicit TLS",
+			tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+			implicit:  true,
+			conf:      MailDestinationConfig{Protocol: SMTPSProtocol, Authentication: BasicAuthentication, User: "alice", Password: "secret", From: "noreply@example.com"},
+			want:      []string{"EHLO localhost", "AUTH PLAIN " + plain, "MAIL FROM:<noreply@example.com>", "RCPT TO:<bob@example.com>", "DATA", "data: Subject: hi", "data: ", "data: hello", "QUIT"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTPServer(t, tt.tlsConfig, tt.implicit)
+			conf := tt.conf
+			conf.Name = "mail"
+			conf.Host = "127.0.0.1"
+			conf.Port = server.port()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := conf.SendMail(ctx, "", []string{"bob@example.com"}, []byte("Subject: hi\r\n\r\nhello\r\n"),
+				WithSMTPTLSConfig(&tls.Config{RootCAs: pool}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := server.received(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected session\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSMTPClientStartTLSRequired(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, false)
+	conf := MailDestinationConfig{Name: "mail", Host: "127.0.0.1", Port: server.port(), StartTLSRequired: "true"}
+	if _, err := conf.SMTPClient(context.Background()); err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("expected an error about STARTTLS, got %v", err)
+	}
+
+	conf = MailDestinationConfig{Name: "mail", Host: "127.0.0.1", ProxyType: OnPremiseProxy}
+	if _, err := conf.SMTPClient(context.Background()); err == nil || !strings.Contains(err.Error(), "SOCKS5") {
+		t.Errorf("expected an error about the missing SOCKS5 dialer, got %v", err)
+	}
+}
+
+// redirectDialer dials address instead of the requested one, standing in for the SOCKS5 tunnel of a ConnectivityProxy
+type redirectDialer struct {
+	address string
+}
+
+func (d redirectDialer) DialContext(ctx context.Context, network, _ string) (net.Conn, error) {
+	return (&net.Dialer{}).DialContext(ctx, network, d.address)
+}
+
+func TestSendMailOnPremisePlainAuthentication(t *testing.T) {
+	server := newFakeSMTPServer(t, nil, false)
+	conf := MailDestinationConfig{Name: "mail", Host: "mail.corp.local", ProxyType: OnPremiseProxy,
+		Authentication: BasicAuthentication, User: "alice", Password: "secret", From: "noreply@example.com"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := conf.SendMail(ctx, "", []string{"bob@example.com"}, []byte("hello\r\n"),
+		WithSMTPDialer(redirectDialer{address: server.listener.Addr().String()}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := "AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00alice\x00secret"))
+	if got := server.received(); len(got) < 2 || got[1] != plain {
+		t.Errorf("expected %q after EHLO, got %q", plain, got)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ErrInvalidConfiguration is wrapped by the errors NewClient returns for an invalid configuration
+var ErrInvalidConfiguration = errors.New("invalid destination client configuration")
+
+// Option configures optional behavior of a DestinationClient
+type Option func(*clientOptions)
+
+type clientOptions struct {
+	timeout     time.Duration
+	userAgent   string
+	retryPolicy *RetryPolicy
+	logger      Logger
+	metrics     Metrics
+	tracer      Tracer
+	cache       Cache
+	transport   http.RoundTripper
+	validate    bool
+}
+
+// Logger receives the log output of the client. The method set matches the logger used by resty
+type Logger interface {
+	Errorf(format string, v ...interface{})
+	Warnf(format string, v ...interface{})
+	Debugf(format string, v ...interface{})
+}
+
+// Metrics receives a measurement for every request attempt sent to the Destination service
+type Metrics interface {
+	// ObserveRequest is called when an attempt completes. The operation is the HTTP method and the path template,
+	// e.g. "GET /destinations/{name}". The statusCode is 0 if no response was received
+	ObserveRequest(operation string, statusCode int, duration time.Duration, err error)
+}
+
+// Tracer starts a span for every request attempt sent to the Destination service
+type Tracer interface {
+	// Start starts a span for the operation, which is the HTTP method and the path template. The returned context is used
+	// for the attempt, and the returned function is called when the attempt completes
+	Start(ctx context.Context, operation string) (context.Context, func(statusCode int, err error))
+}
+
+// WithTimeout sets the timeout of requests to the service, including fetching the token
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent to the service
+func WithUserAgent(userAgent string) Option {
+	return func(o *clientOptions) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithRetryPolicy sets the retry policy, overriding DestinationClientConfiguration.RetryPolicy
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *clientOptions) {
+		o.retryPolicy = &policy
+	}
+}
+
+// WithLogger sets the logger of the client
+func WithLogger(logger Logger) Option {
+	return func(o *clientOptions) {
+		o.logger = logger
+	}
+}
+
+// WithMetrics reports request measurements to metrics
+func WithMetrics(metrics Metrics) Option {
+	return func(o *clientOptions) {
+		o.metrics = metrics
+	}
+}
+
+// WithTracer traces requests with tracer
+func WithTracer(tracer Tracer) Option {
+	return func(o *clientOptions) {
+		o.tracer = tracer
+	}
+}
+
+// WithCache caches the results of Find operations in cache
+func WithCache(cache Cache) Option {
+	return func(o *clientOptions) {
+		o.cache = cache
+	}
+}
+
+// WithTransport sets the transport for requests to the service and the token endpoint, overriding DestinationClientConfiguration.Transport
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *clientOptions) {
+		o.transport = transport
+	}
+}
+
+// WithValidation checks destinations with Validate before creating or updating them. Invalid destinations are not sent
+// to the service, and the returned error wraps ErrInvalidDestination
+func WithValidation() Option {
+	return func(o *clientOptions) {
+		o.validate = true
+	}
+}
+
+// validate checks that the configuration contains the values required for creating a client
+func (c DestinationClientConfiguration) validate() error {
+	var errs []error
+	if err := validateURL("ServiceURL", c.ServiceURL); err != nil {
+		errs = append(errs, err)
+	}
+	if c.TokenSource == nil {
+		if c.ClientID == "" {
+			errs = append(errs, errors.New("ClientID is required"))
+		}
+		switch c.CredentialType {
+		case X509Credential:
+			if c.Certificate == "" && c.CertificateFile == "" {
+				errs = append(errs, errors.New("Certificate or CertificateFile is required for X509Credential"))
+			}
+			if c.Key == "" && c.KeyFile == "" {
+				errs = append(errs, errors.New("Key or KeyFile is required for X509Credential"))
+			}
+			if c.CertURL != "" {
+				if err := validateURL("CertURL", c.CertURL); err != nil {
+					errs = append(errs, err)
+				}
+			} else if err := validateURL("TokenURL", c.TokenURL); err != nil {
+				errs = append(errs, err)
+			}
+		default:
+			if c.ClientSecret == "" {
+				errs = append(errs, errors.New("ClientSecret is required"))
+			}
+			if err := validateURL("TokenURL", c.TokenURL); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidConfiguration, errors.Join(errs...))
+	}
+	return nil
+}
+
+func validateURL(field string, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s is required", field)
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("%s is malformed: %w", field, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s %q must be an absolute http or https URL", field, value)
+	}
+	return nil
+}
+
+// operationKey is the context key of the operation name used for metrics and tracing
+type operationKey struct{}
+
+// setOperation records the operation of the request in its context, before the path parameters are substituted
+func setOperation(_ *resty.Client, r *resty.Request) error {
+	if _, ok := r.Context().Value(operationKey{}).(string); !ok {
+		r.SetContext(context.WithValue(r.Context(), operationKey{}, r.Method+" "+r.URL))
+	}
+	return nil
+}
+
+// instrumentedTransport reports metrics and traces for every request attempt
+type instrumentedTransport struct {
+	base    http.RoundTripper
+	metrics Metrics
+	tracer  Tracer
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	operation, ok := req.Context().Value(operationKey{}).(string)
+	if !ok {
+		operation = req.Method + " " + req.URL.Path
+	}
+	var end func(int, error)
+	if t.tracer != nil {
+		var ctx context.Context
+		ctx, end = t.tracer.Start(req.Context(), operation)
+		req = req.WithContext(ctx)
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+
+	statusCode := 0
+	if resp != nil {
+		statusCode = resp.StatusCode
+	}
+	if t.metrics != nil {
+		t.metrics.ObserveRequest(operation, statusCode, time.Since(start), err)
+	}
+	if end != nil {
+		end(statusCode, err)
+	}
+	return resp, err
+}
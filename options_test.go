@@ -0,0 +1,184 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewClientValidation(t *testing.T) {
+	valid := DestinationClientConfiguration{
+		ClientID:     "clientid",
+		ClientSecret: "clientsecret",
+		TokenURL:     "https://subdomain.authentication.eu10.hana.ondemand.com",
+		ServiceURL:   "https://destination-configuration.cfapps.eu10.hana.ondemand.com",
+	}
+	tests := []struct {
+		name    string
+		modify  func(*DestinationClientConfiguration)
+		message string
+	}{
+		{"empty ServiceURL", func(c *DestinationClientConfiguration) { c.ServiceURL = "" }, "ServiceURL is required"},
+		{"relative ServiceURL", func(c *DestinationClientConfiguration) { c.ServiceURL = "destination.example.com" }, "ServiceURL"},
+		{"malformed TokenURL", func(c *DestinationClientConfiguration) { c.TokenURL = "https://%zz" }, "TokenURL is malformed"},
+		{"missing secret", func(c *DestinationClientConfiguration) { c.ClientSecret = "" }, "ClientSecret is required"},
+		{"missing key", func(c *DestinationClientConfiguration) {
+			c.CredentialType = X509Credential
+			c.Certificate = "cert"
+		}, "Key or KeyFile is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := valid
+			tt.modify(&conf)
+			_, err := NewClient(conf)
+			if !errors.Is(err, ErrInvalidConfiguration) {
+				t.Fatalf("expected ErrInvalidConfiguration, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("expected %q in %q", tt.message, err)
+			}
+		})
+	}
+	if _, err := NewClient(valid); err != nil {
+		t.Errorf("unexpected error for a valid configuration: %v", err)
+	}
+}
+
+type recordingObserver struct {
+	mu         sync.Mutex
+	operations []string
+	spans      []string
+}
+
+func (r *recordingObserver) ObserveRequest(operation string, statusCode int, duration time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.operations = append(r.operations, fmt.Sprintf("%s %d", operation, statusCode))
+}
+
+type spanKey struct{}
+
+func (r *recordingObserver) Start(ctx context.Context, operation string) (context.Context, func(int, error)) {
+	return context.WithValue(ctx, spanKey{}, operation), func(statusCode int, err error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.spans = append(r.spans, fmt.Sprintf("%s %d", operation, statusCode))
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	var finds int
+	observer := &recordingObserver{}
+	var spanOperation string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"test-token","token_type":"bearer","expires_in":3600}`)
+	})
+	mux.HandleFunc("/destination-configuration/v1/destinations/dest1", func(w http.ResponseWriter, r *http.Request) {
+		finds++
+		if got := r.Header.Get("User-Agent"); got != "test-agent/1.0" {
+			t.Errorf("unexpected User-Agent %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"destinationConfiguration":{"Name":"dest1","Type":"HTTP"}}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tracingTransport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if operation, ok := r.Context().Value(spanKey{}).(string); ok {
+			spanOperation = operation
+		}
+		return http.DefaultTransport.RoundTrip(r)
+	})
+
+	client, err := NewClient(DestinationClientConfiguration{
+		ClientID:     "clientid",
+		ClientSecret: "clientsecret",
+		TokenURL:     server.URL,
+		ServiceURL:   server.URL,
+	},
+		WithUserAgent("test-agent/1.0"),
+		WithTimeout(5*time.Second),
+		WithMetrics(observer),
+		WithTracer(observer),
+		WithCache(NewMemoryCache(time.Minute)),
+		WithTransport(tracingTransport),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Find("dest1", "user-token"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if finds != 1 {
+		t.Errorf("expected the second lookup to be cached, got %d requests", finds)
+	}
+	if want := []string{"GET /destinations/{name} 200"}; fmt.Sprint(observer.operations) != fmt.Sprint(want) {
+		t.Errorf("got metrics %v, want %v", observer.operations, want)
+	}
+	if fmt.Sprint(observer.spans) != fmt.Sprint(observer.operations) {
+		t.Errorf("got spans %v, want %v", observer.spans, observer.operations)
+	}
+	if spanOperation != "GET /destinations/{name}" {
+		t.Errorf("expected the span context to reach the transport, got %q", spanOperation)
+	}
+}
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	cache := NewMemoryCache(time.Millisecond)
+	cache.Set("key", DestinationLookupResult{Destination: Destination{Name: "dest1"}})
+	if _, ok := cache.Get("key"); !ok {
+		t.Fatal("expected a cached result")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.Get("key"); ok {
+		t.Error("expected the cached result to expire")
+	}
+}
+
+func TestMemoryCacheTokenExpiry(t *testing.T) {
+	cache := NewMemoryCache(time.Hour)
+	expiring := func(in time.Duration) DestinationLookupResult {
+		return DestinationLookupResult{AuthTokens: []AuthToken{{Type: "Bearer", Value: "token", ExpiresAt: time.Now().Add(in)}}}
+	}
+	cache.Set("soon", expiring(tokenExpirySkew/2))
+	if _, ok := cache.Get("soon"); ok {
+		t.Error("expected a token expiring within the skew not to be cached")
+	}
+	cache.Set("later", expiring(time.Minute))
+	if _, ok := cache.Get("later"); !ok {
+		t.Error("expected a cached result")
+	}
+	if expires := cache.entries["later"].expires; expires.After(time.Now().Add(time.Minute - tokenExpirySkew)) {
+		t.Errorf("expected the entry to expire before the token, at %v", expires)
+	}
+}
@@ -0,0 +1,139 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// RetryPolicy describes how failed requests to the Destination service are retried.
+// Only idempotent operations (GET, PUT and DELETE, which includes Find) are retried unless RetryCreate is set.
+type RetryPolicy struct {
+	// Total number of attempts, including the first one. Values lower than 2 disable retries
+	MaxAttempts int
+	// Wait time before the first retry. Later retries back off exponentially, with jitter
+	MinBackoff time.Duration
+	// Upper bound for the wait time between attempts, including waits requested by a Retry-After header
+	MaxBackoff time.Duration
+	// Response status codes that cause a retry. DefaultRetryStatusCodes is used when empty
+	RetryStatusCodes []int
+	// Allow retrying the create operations, which are not idempotent
+	RetryCreate bool
+}
+
+// DefaultRetryStatusCodes are the response status codes retried when RetryPolicy.RetryStatusCodes is empty
+var DefaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+)
+
+// DefaultRetryPolicy returns a RetryPolicy with three attempts and the default backoff and status codes
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  defaultMinBackoff,
+		MaxBackoff:  defaultMaxBackoff,
+	}
+}
+
+// apply configures the resty client to retry requests according to the policy
+func (p RetryPolicy) apply(restyClient *resty.Client) {
+	if p.MaxAttempts < 2 {
+		return
+	}
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = defaultMinBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultMaxBackoff
+	}
+	if len(p.RetryStatusCodes) == 0 {
+		p.RetryStatusCodes = DefaultRetryStatusCodes
+	}
+	restyClient.
+		SetRetryCount(p.MaxAttempts - 1).
+		SetRetryWaitTime(p.MinBackoff).
+		SetRetryMaxWaitTime(p.MaxBackoff).
+		SetRetryAfter(retryAfter).
+		AddRetryCondition(p.shouldRetry).
+		AddRetryHook(releaseBody)
+}
+
+// maxRetriedBody limits how much of the body of a retried response is kept
+const maxRetriedBody = 64 << 10
+
+// releaseBody reads and closes the body of a response that is retried, so its connection is released even if the
+// request asked resty not to parse the response. The body stays readable from memory, as the hook also runs for
+// the last attempt, whose response is returned to the caller.
+func releaseBody(response *resty.Response, _ error) {
+	if response == nil || response.RawResponse == nil || response.RawResponse.Body == nil {
+		return
+	}
+	body := response.RawResponse.Body
+	data, _ := io.ReadAll(io.LimitReader(body, maxRetriedBody))
+	body.Close()
+	response.RawResponse.Body = io.NopCloser(bytes.NewReader(data))
+}
+
+// shouldRetry decides whether a request is retried after it failed with err or returned response
+func (p RetryPolicy) shouldRetry(response *resty.Response, err error) bool {
+	if response == nil || response.Request == nil {
+		return false
+	}
+	switch response.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+	case http.MethodPost:
+		if !p.RetryCreate {
+			return false
+		}
+	default:
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return slices.Contains(p.RetryStatusCodes, response.StatusCode())
+}
+
+// retryAfter returns the wait time requested by the Retry-After header of the response, or 0 to use the default backoff
+func retryAfter(_ *resty.Client, response *resty.Response) (time.Duration, error) {
+	value := response.Header().Get("Retry-After")
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	if date, err := http.ParseTime(value); err == nil && time.Until(date) > 0 {
+		return time.Until(date), nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,179 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// flakyHandler fails the first failures requests with status, then succeeds
+func flakyHandler(failures int32, status int, header http.Header, attempts *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if attempts.Add(1) <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"ErrorMessage":"try again"}`)
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+		default:
+			fmt.Fprint(w, `{"Name":"dest1","Type":"HTTP"}`)
+		}
+	})
+}
+
+func retryingClient(policy RetryPolicy) func(*DestinationClientConfiguration) {
+	return func(conf *DestinationClientConfiguration) {
+		conf.RetryPolicy = &policy
+	}
+}
+
+func TestRetryIdempotent(t *testing.T) {
+	var attempts atomic.Int32
+	client, _ := newTestClientWithConf(t, flakyHandler(2, http.StatusBadGateway, nil, &attempts),
+		retryingClient(RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}))
+
+	if _, err := client.GetSubaccountDestination("dest1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var attempts atomic.Int32
+	client, _ := newTestClientWithConf(t, flakyHandler(5, http.StatusServiceUnavailable, nil, &attempts),
+		retryingClient(RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}))
+
+	if _, err := client.GetSubaccountDestination("dest1"); !errors.Is(err, ErrServerError) {
+		t.Fatalf("expected ErrServerError, got %v", err)
+	}
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("expected 2 attempts, got %d", got)
+	}
+}
+
+func TestRetrySkipsCreate(t *testing.T) {
+	var attempts atomic.Int32
+	client, _ := newTestClientWithConf(t, flakyHandler(1, http.StatusBadGateway, nil, &attempts),
+		retryingClient(RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond}))
+
+	if err := client.CreateSubaccountDestination(Destination{Name: "dest1", Type: HTTPDestination}); !errors.Is(err, ErrServerError) {
+		t.Fatalf("expected ErrServerError, got %v", err)
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("expected 1 attempt, got %d", got)
+	}
+}
+
+func TestRetryCreateOptIn(t *testing.T) {
+	var attempts atomic.Int32
+	client, _ := newTestClientWithConf(t, flakyHandler(1, http.StatusBadGateway, nil, &attempts),
+		retryingClient(RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, RetryCreate: true}))
+
+	if err := client.CreateInstanceDestination(Destination{Name: "dest1", Type: HTTPDestination}); err != nil {
+		t.Fatal(err)
+	}
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("expected 2 attempts, got %d", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var attempts atomic.Int32
+	client, _ := newTestClientWithConf(t, flakyHandler(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"1"}}, &attempts),
+		retryingClient(RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Second}))
+
+	start := time.Now()
+	if _, err := client.Find("dest1", ""); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("expected the Retry-After header to delay the retry, retried after %v", elapsed)
+	}
+}
+
+func TestRetryReleasesListConnections(t *testing.T) {
+	var attempts atomic.Int32
+	var connections atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if attempts.Add(1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"ErrorMessage":"try again"}`)
+			return
+		}
+		fmt.Fprint(w, `[{"Name":"dest1","Type":"HTTP"}]`)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client, err := NewClient(DestinationClientConfiguration{
+		ServiceURL:  server.URL,
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "static-token"}),
+		RetryPolicy: &RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		destinations, err := client.GetSubaccountDestinations()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(destinations) != 1 {
+			t.Errorf("unexpected destinations %v", destinations)
+		}
+	}
+	if got := attempts.Load(); got != 6 {
+		t.Errorf("expected 6 attempts, got %d", got)
+	}
+	if got := connections.Load(); got != 1 {
+		t.Errorf("expected the retried responses to release their connection, opened %d connections", got)
+	}
+}
+
+func TestRetryGivesUpOnList(t *testing.T) {
+	var attempts atomic.Int32
+	client, _ := newTestClientWithConf(t, flakyHandler(5, http.StatusServiceUnavailable, nil, &attempts),
+		retryingClient(RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}))
+
+	_, err := client.GetInstanceCertificates()
+	if !errors.Is(err, ErrServerError) || err.Error() != "try again" {
+		t.Fatalf("expected ErrServerError with the message of the last response, got %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+// RFCConnectionMode describes how an RFC destination reaches the ABAP system
+type RFCConnectionMode string
+
+const (
+	// RFCDirectConnection connects to a specific application server, using jco.client.ashost and jco.client.sysnr
+	RFCDirectConnection RFCConnectionMode = "direct"
+	// RFCMessageServerConnection balances the load using the message server, using jco.client.mshost and
+	// jco.client.r3name or jco.client.msserv
+	RFCMessageServerConnection RFCConnectionMode = "message-server"
+	// RFCWebSocketConnection connects with WebSocket RFC, using jco.client.wshost and jco.client.wsport
+	RFCWebSocketConnection RFCConnectionMode = "websocket"
+)
+
+// Values of the jco.destination.auth_type property
+const (
+	RFCConfiguredUser       = "CONFIGURED_USER"
+	RFCPrincipalPropagation = "PrincipalPropagation"
+)
+
+// RFCDestinationConfig is the typed configuration of an RFC destination.
+// Fields hold the jco.* values unconverted, so numbers like the pool capacity remain strings. Any other property ends up
+// in AdditionalProperties, or in RawProperties if its JSON value is not a string, and Destination restores both.
+type RFCDestinationConfig struct {
+	// The name of the destination
+	Name string
+	// Description property
+	Description string
+	// jco.destination.proxy_type property, InternetProxy or OnPremiseProxy
+	ProxyType string
+	// LocationID property, selecting the Cloud Connector of OnPremise destinations
+	LocationID string
+	// jco.destination.auth_type property, RFCConfiguredUser or RFCPrincipalPropagation
+	AuthType string
+
+	// jco.client.client property, the three digit ABAP client
+	Client string
+	// jco.client.user property
+	User string
+	// jco.client.passwd property
+	Password string
+	// jco.client.lang property
+	Language string
+
+	// jco.client.ashost property, for direct connections
+	ApplicationServerHost string
+	// jco.client.sysnr property, the two digit system number for direct connections
+	SystemNumber string
+
+	// jco.client.mshost property, for message server connections
+	MessageServerHost string
+	// jco.client.msserv property, the message server port
+	MessageServerService string
+	// jco.client.r3name property, the system ID
+	SystemID string
+	// jco.client.group property, the logon group
+	Group string
+
+	// jco.client.wshost property, for WebSocket RFC connections
+	WebSocketHost string
+	// jco.client.wsport property
+	WebSocketPort string
+
+	// jco.destination.pool_capacity property
+	PoolCapacity string
+	// jco.destination.peak_limit property
+	PeakLimit string
+
+	// Properties that have no field
+	AdditionalProperties map[string]string
+	// Properties whose values are not JSON strings, from Destination.RawProperties
+	RawProperties map[string]json.RawMessage
+}
+
+func (c *RFCDestinationConfig) properties() []configProperty {
+	return []configProperty{
+		{DescriptionProperty, &c.Description},
+		{"jco.destination.proxy_type", &c.ProxyType},
+		{LocationIDProperty, &c.LocationID},
+		{"jco.destination.auth_type", &c.AuthType},
+		{"jco.client.client", &c.Client},
+		{"jco.client.user", &c.User},
+		{"jco.client.passwd", &c.Password},
+		{"jco.client.lang", &c.Language},
+		{"jco.client.ashost", &c.ApplicationServerHost},
+		{"jco.client.sysnr", &c.SystemNumber},
+		{"jco.client.mshost", &c.MessageServerHost},
+		{"jco.client.msserv", &c.MessageServerService},
+		{"jco.client.r3name", &c.SystemID},
+		{"jco.client.group", &c.Group},
+		{"jco.client.wshost", &c.WebSocketHost},
+		{"jco.client.wsport", &c.WebSocketPort},
+		{"jco.destination.pool_capacity", &c.PoolCapacity},
+		{"jco.destination.peak_limit", &c.PeakLimit},
+	}
+}
+
+// NewRFCDestinationConfig converts a destination of type RFC to its typed configuration
+func NewRFCDestinationConfig(d Destination) (RFCDestinationConfig, error) {
+	if d.Type != RFCDestination {
+		return RFCDestinationConfig{}, fmt.Errorf("destination %q has type %q, expected %q", d.Name, d.Type, RFCDestination)
+	}
+	c := RFCDestinationConfig{Name: d.Name, RawProperties: maps.Clone(d.RawProperties)}
+	c.AdditionalProperties = readConfigProperties(d.Properties, c.properties())
+	return c, nil
+}
+
+// Destination converts the typed configuration to a Destination of type RFC
+func (c RFCDestinationConfig) Destination() Destination {
+	return Destination{
+		Name:          c.Name,
+		Type:          RFCDestination,
+		Properties:    writeConfigProperties(c.AdditionalProperties, c.properties()),
+		RawProperties: maps.Clone(c.RawProperties),
+	}
+}
+
+// ConnectionMode returns the connection mode selected by the configured hosts, or an empty mode if no host or more
+// than one kind of host is configured
+func (c RFCDestinationConfig) ConnectionMode() RFCConnectionMode {
+	var modes []RFCConnectionMode
+	if c.ApplicationServerHost != "" {
+		modes = append(modes, RFCDirectConnection)
+	}
+	if c.MessageServerHost != "" {
+		modes = append(modes, RFCMessageServerConnection)
+	}
+	if c.WebSocketHost != "" {
+		modes = append(modes, RFCWebSocketConnection)
+	}
+	if len(modes) != 1 {
+		return ""
+	}
+	return modes[0]
+}
+
+// Validate checks that the mandatory properties of the connection mode are present. The returned error wraps
+// ErrInvalidDestination and a ValidationError for every problem found.
+func (c RFCDestinationConfig) Validate() error {
+	var v validationErrors
+	c.validate(&v)
+	return v.err(c.Name)
+}
+
+func (c RFCDestinationConfig) validate(v *validationErrors) {
+	switch c.ConnectionMode() {
+	case RFCDirectConnection:
+		if c.SystemNumber == "" {
+			v.require("jco.client.sysnr", c.SystemNumber)
+		} else if !isDigits(c.SystemNumber, 2) {
+			v.add("jco.client.sysnr", "jco.client.sysnr %q must be two digits", c.SystemNumber)
+		}
+	case RFCMessageServerConnection:
+		if c.SystemID == "" && c.MessageServerService == "" {
+			v.add("jco.client.r3name", "jco.client.r3name or jco.client.msserv is required")
+		}
+	case RFCWebSocketConnection:
+		if c.WebSocketPort == "" {
+			v.require("jco.client.wsport", c.WebSocketPort)
+		} else if !isPort(c.WebSocketPort) {
+			v.add("jco.client.wsport", "jco.client.wsport %q is not a valid port", c.WebSocketPort)
+		}
+	default:
+		var hosts []string
+		for _, host := range []configProperty{
+			{"jco.client.ashost", &c.ApplicationServerHost},
+			{"jco.client.mshost", &c.MessageServerHost},
+			{"jco.client.wshost", &c.WebSocketHost},
+		} {
+			if *host.value != "" {
+				hosts = append(hosts, host.name)
+			}
+		}
+		if len(hosts) == 0 {
+			v.add("jco.client.ashost", "one of jco.client.ashost, jco.client.mshost or jco.client.wshost is required")
+		} else {
+			v.add(hosts[0], "only one of %s may be set", strings.Join(hosts, ", "))
+		}
+	}
+
+	if c.Client == "" {
+		v.require("jco.client.client", c.Client)
+	} else if !isDigits(c.Client, 3) {
+		v.add("jco.client.client", "jco.client.client %q must be three digits", c.Client)
+	}
+	switch c.AuthType {
+	case "", RFCConfiguredUser:
+		v.require("jco.client.user", c.User)
+		v.require("jco.client.passwd", c.Password)
+	case RFCPrincipalPropagation:
+	default:
+		v.add("jco.destination.auth_type", "jco.destination.auth_type %q is not supported", c.AuthType)
+	}
+	switch c.ProxyType {
+	case "", InternetProxy, OnPremiseProxy:
+	default:
+		v.add("jco.destination.proxy_type", "jco.destination.proxy_type %q is not supported", c.ProxyType)
+	}
+	for _, limit := range []configProperty{
+		{"jco.destination.pool_capacity", &c.PoolCapacity},
+		{"jco.destination.peak_limit", &c.PeakLimit},
+	} {
+		if *limit.value == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(*limit.value); err != nil || n < 0 {
+			v.add(limit.name, "%s %q must be a non-negative number", limit.name, *limit.value)
+		}
+	}
+}
+
+// isDigits reports whether value consists of exactly n decimal digits
+func isDigits(value string, n int) bool {
+	if len(value) != n {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isPort(value string) bool {
+	port, err := strconv.Atoi(value)
+	return err == nil && port > 0 && port <= 65535
+}
@@ -0,0 +1,126 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRFCDestinationConfig(t *testing.T) {
+	dest := Destination{
+		Name: "erp",
+		Type: RFCDestination,
+		Properties: map[string]string{
+			"jco.destination.proxy_type":    OnPremiseProxy,
+			LocationIDProperty:              "berlin",
+			"jco.client.client":             "100",
+			"jco.client.user":               "RFC_USER",
+			"jco.client.passwd":             "secret",
+			"jco.client.lang":               "EN",
+			"jco.client.mshost":             "erp.corp.local",
+			"jco.client.r3name":             "ERP",
+			"jco.client.group":              "PUBLIC",
+			"jco.destination.pool_capacity": "5",
+			"jco.destination.peak_limit":    "10",
+			"jco.client.trace":              "0",
+		},
+	}
+	conf, err := NewRFCDestinationConfig(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := RFCDestinationConfig{
+		Name:                 "erp",
+		ProxyType:            OnPremiseProxy,
+		LocationID:           "berlin",
+		Client:               "100",
+		User:                 "RFC_USER",
+		Password:             "secret",
+		Language:             "EN",
+		MessageServerHost:    "erp.corp.local",
+		SystemID:             "ERP",
+		Group:                "PUBLIC",
+		PoolCapacity:         "5",
+		PeakLimit:            "10",
+		AdditionalProperties: map[string]string{"jco.client.trace": "0"},
+	}
+	if !reflect.DeepEqual(conf, want) {
+		t.Errorf("unexpected configuration\n got %+v\nwant %+v", conf, want)
+	}
+	if mode := conf.ConnectionMode(); mode != RFCMessageServerConnection {
+		t.Errorf("expected a message server connection, got %q", mode)
+	}
+	if got := conf.Destination(); !reflect.DeepEqual(got, dest) {
+		t.Errorf("round trip changed the destination\n got %+v\nwant %+v", got, dest)
+	}
+}
+
+func TestRFCDestinationConfigValidation(t *testing.T) {
+	credentials := func(properties map[string]string) map[string]string {
+		properties["jco.client.client"] = "100"
+		properties["jco.client.user"] = "RFC_USER"
+		properties["jco.client.passwd"] = "secret"
+		return properties
+	}
+	tests := []struct {
+		name       string
+		properties map[string]string
+		mode       RFCConnectionMode
+		want       []string
+	}{
+		{"direct", credentials(map[string]string{"jco.client.ashost": "erp", "jco.client.sysnr": "00"}), RFCDirectConnection, nil},
+		{"direct without sysnr", credentials(map[string]string{"jco.client.ashost": "erp"}), RFCDirectConnection, []string{"jco.client.sysnr is required"}},
+		{"direct with invalid sysnr", credentials(map[string]string{"jco.client.ashost": "erp", "jco.client.sysnr": "1"}), RFCDirectConnection, []string{"two digits"}},
+		{"message server with msserv", credentials(map[string]string{"jco.client.mshost": "erp", "jco.client.msserv": "3600"}), RFCMessageServerConnection, nil},
+		{"message server without system", credentials(map[string]string{"jco.client.mshost": "erp"}), RFCMessageServerConnection, []string{"jco.client.r3name or jco.client.msserv is required"}},
+		{"websocket", credentials(map[string]string{"jco.client.wshost": "erp", "jco.client.wsport": "443"}), RFCWebSocketConnection, nil},
+		{"websocket without port", credentials(map[string]string{"jco.client.wshost": "erp"}), RFCWebSocketConnection, []string{"jco.client.wsport is required"}},
+		{"no host", credentials(map[string]string{}), "", []string{"one of jco.client.ashost, jco.client.mshost or jco.client.wshost is required"}},
+		{"two hosts", credentials(map[string]string{"jco.client.ashost": "erp", "jco.client.mshost": "erp"}), "", []string{"only one of jco.client.ashost, jco.client.mshost"}},
+		{"missing client and user", map[string]string{"jco.client.ashost": "erp", "jco.client.sysnr": "00"}, RFCDirectConnection, []string{"jco.client.client is required", "jco.client.user is required", "jco.client.passwd is required"}},
+		{"principal propagation", map[string]string{"jco.client.ashost": "erp", "jco.client.sysnr": "00", "jco.client.client": "100", "jco.destination.auth_type": RFCPrincipalPropagation}, RFCDirectConnection, nil},
+		{"pool capacity", credentials(map[string]string{"jco.client.ashost": "erp", "jco.client.sysnr": "00", "jco.destination.pool_capacity": "many"}), RFCDirectConnection, []string{"jco.destination.pool_capacity"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := NewRFCDestinationConfig(Destination{Name: "erp", Type: RFCDestination, Properties: tt.properties})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = conf.Validate()
+			if mode := conf.ConnectionMode(); mode != tt.mode {
+				t.Errorf("expected connection mode %q, got %q", tt.mode, mode)
+			}
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected a validation error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected %q in %q", want, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"fmt"
+	"iter"
+)
+
+// Scope selects the level on which destinations, certificates and fragments are managed
+type Scope string
+
+const (
+	// SubaccountScope manages the items posted on the subaccount level, shared by all the service instances of the subaccount
+	SubaccountScope Scope = "subaccount"
+	// InstanceScope manages the items posted on the level of the service instance the client is bound to
+	InstanceScope Scope = "instance"
+)
+
+// ScopedCollection provides the operations on the destinations, certificates or fragments of a single Scope.
+// The scope and subaccount are determined by the passed OAuth access token.
+type ScopedCollection[T any] interface {
+	// List retrieves all the items. If none are found, an empty list is returned
+	List(ctx context.Context) ([]T, error)
+	// ListPage retrieves a page of the items selected by opts
+	ListPage(ctx context.Context, opts ListOptions) (Page[T], error)
+	// All returns an iterator over the items selected by opts, fetching the pages as the iteration proceeds
+	All(ctx context.Context, opts ListOptions) iter.Seq2[T, error]
+	// Get retrieves a named item
+	Get(ctx context.Context, name string) (T, error)
+	// Create creates a new item
+	Create(ctx context.Context, item T) error
+	// Delete deletes a named item
+	Delete(ctx context.Context, name string) (AffectedRecords, error)
+}
+
+// ScopedManager adds updates to a ScopedCollection, for destinations and fragments. The service does not update
+// certificates, they are deleted and created again instead.
+type ScopedManager[T any] interface {
+	ScopedCollection[T]
+	// Update updates (overwrites) an existing item with the same name
+	Update(ctx context.Context, item T) (AffectedRecords, error)
+}
+
+// Destinations returns the manager of the destinations on the given scope
+func (d *DestinationClient) Destinations(scope Scope) ScopedManager[Destination] {
+	return &scopedManager[Destination]{&scopedCollection[Destination]{client: d, scope: scope, collection: "Destinations"}}
+}
+
+// Certificates returns the collection of the certificates on the given scope
+func (d *DestinationClient) Certificates(scope Scope) ScopedCollection[Certificate] {
+	return &scopedCollection[Certificate]{client: d, scope: scope, collection: "Certificates"}
+}
+
+// Fragments returns the manager of the destination fragments on the given scope
+func (d *DestinationClient) Fragments(scope Scope) ScopedManager[Fragment] {
+	return &scopedManager[Fragment]{&scopedCollection[Fragment]{client: d, scope: scope, collection: "DestinationFragments"}}
+}
+
+// scopedCollection implements ScopedCollection for the collection of the scope, e.g. /subaccountDestinations
+type scopedCollection[T any] struct {
+	client     *DestinationClient
+	scope      Scope
+	collection string
+}
+
+// scopedManager implements ScopedManager
+type scopedManager[T any] struct {
+	*scopedCollection[T]
+}
+
+// path returns the path of the collection
+func (m *scopedCollection[T]) path() (string, error) {
+	switch m.scope {
+	case SubaccountScope, InstanceScope:
+		return "/" + string(m.scope) + m.collection, nil
+	}
+	return "", fmt.Errorf("unknown scope %q", m.scope)
+}
+
+// List implements ScopedCollection
+func (m *scopedCollection[T]) List(ctx context.Context) ([]T, error) {
+	path, err := m.path()
+	if err != nil {
+		return make([]T, 0), err
+	}
+	response, err := getList(ctx, m.client, path, nil)
+	if err != nil {
+		return make([]T, 0), err
+	}
+	return readList[T](response)
+}
+
+// ListPage implements ScopedCollection
+func (m *scopedCollection[T]) ListPage(ctx context.Context, opts ListOptions) (Page[T], error) {
+	path, err := m.path()
+	if err != nil {
+		return Page[T]{Items: make([]T, 0), Number: opts.Page}, err
+	}
+	return listPage[T](ctx, m.client, path, opts)
+}
+
+// All implements ScopedCollection
+func (m *scopedCollection[T]) All(ctx context.Context, opts ListOptions) iter.Seq2[T, error] {
+	path, err := m.path()
+	if err != nil {
+		return func(yield func(T, error) bool) {
+			var zero T
+			yield(zero, err)
+		}
+	}
+	return allItems[T](ctx, m.client, path, opts)
+}
+
+// Get implements ScopedCollection
+func (m *scopedCollection[T]) Get(ctx context.Context, name string) (T, error) {
+
+	var retval T
+	var errResponse ErrorMessage
+
+	path, err := m.path()
+	if err != nil {
+		return retval, err
+	}
+
+	response, err := m.client.restyClient.R().
+		SetContext(ctx).
+		SetResult(&retval).
+		SetError(&errResponse).
+		SetPathParams(map[string]string{
+			"name": name,
+		}).
+		Get(path + "/{name}")
+
+	if err != nil {
+		return retval, err
+	}
+	if response.StatusCode() != 200 {
+		return retval, newErrorMessage(response, errResponse)
+	}
+	return retval, nil
+}
+
+// Create implements ScopedCollection. Destinations are validated first if enabled with WithValidation.
+func (m *scopedCollection[T]) Create(ctx context.Context, item T) error {
+
+	var errResponse ErrorMessage
+
+	path, err := m.path()
+	if err != nil {
+		return err
+	}
+	if err := m.check(item); err != nil {
+		return err
+	}
+
+	response, err := m.client.restyClient.R().
+		SetContext(ctx).
+		SetBody(item).
+		SetError(&errResponse).
+		Post(path)
+
+	if err != nil {
+		return err
+	}
+	if response.StatusCode() != 201 {
+		return newErrorMessage(response, errResponse)
+	}
+	return nil
+}
+
+// Update implements ScopedManager. Destinations are validated first if enabled with WithValidation.
+func (m *scopedManager[T]) Update(ctx context.Context, item T) (AffectedRecords, error) {
+
+	var retval AffectedRecords
+	var errResponse ErrorMessage
+
+	path, err := m.path()
+	if err != nil {
+		return retval, err
+	}
+	if err := m.check(item); err != nil {
+		return retval, err
+	}
+
+	response, err := m.client.restyClient.R().
+		SetContext(ctx).
+		SetBody(item).
+		SetResult(&retval).
+		SetError(&errResponse).
+		Put(path)
+
+	if err != nil {
+		return retval, err
+	}
+	if response.StatusCode() != 200 {
+		return retval, newErrorMessage(response, errResponse)
+	}
+	return retval, nil
+}
+
+// Delete implements ScopedCollection
+func (m *scopedCollection[T]) Delete(ctx context.Context, name string) (AffectedRecords, error) {
+
+	var retval AffectedRecords
+	var errResponse ErrorMessage
+
+	path, err := m.path()
+	if err != nil {
+		return retval, err
+	}
+
+	response, err := m.client.restyClient.R().
+		SetContext(ctx).
+		SetResult(&retval).
+		SetError(&errResponse).
+		SetPathParams(map[string]string{
+			"name": name,
+		}).
+		Delete(path + "/{name}")
+
+	if err != nil {
+		return retval, err
+	}
+	if response.StatusCode() != 200 {
+		return retval, newErrorMessage(response, errResponse)
+	}
+	return retval, nil
+}
+
+// check validates destinations before they are sent to the service
+func (m *scopedCollection[T]) check(item T) error {
+	if dest, ok := any(item).(Destination); ok {
+		return m.client.checkDestination(dest)
+	}
+	return nil
+}
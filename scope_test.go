@@ -0,0 +1,190 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// recordingService records the method and path of every request, answering with an empty list for collections
+// and an empty object otherwise
+func recordingService(t *testing.T) (*DestinationClient, *[]string) {
+	var requests []string
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodGet && strings.Count(r.URL.Path, "/") == 1:
+			fmt.Fprint(w, `[]`)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	return client, &requests
+}
+
+// crudCalls calls every operation of collection, including Update if it is a ScopedManager, and returns the errors
+func crudCalls[T any](collection ScopedCollection[T], item T) []error {
+	ctx := context.Background()
+	_, listErr := collection.List(ctx)
+	_, pageErr := collection.ListPage(ctx, ListOptions{Page: 1})
+	var allErr error
+	for _, err := range collection.All(ctx, ListOptions{}) {
+		allErr = err
+	}
+	_, getErr := collection.Get(ctx, "name1")
+	createErr := collection.Create(ctx, item)
+	errs := []error{listErr, pageErr, allErr, getErr, createErr}
+	if manager, ok := collection.(ScopedManager[T]); ok {
+		_, updateErr := manager.Update(ctx, item)
+		errs = append(errs, updateErr)
+	}
+	_, deleteErr := collection.Delete(ctx, "name1")
+	return append(errs, deleteErr)
+}
+
+func TestScopedManagerPaths(t *testing.T) {
+	tests := []struct {
+		scope      Scope
+		collection string
+		calls      func(*DestinationClient, Scope) []error
+	}{
+		{SubaccountScope, "/subaccountDestinations", func(c *DestinationClient, s Scope) []error {
+			return crudCalls(c.Destinations(s), Destination{Name: "name1", Type: HTTPDestination})
+		}},
+		{InstanceScope, "/instanceDestinations", func(c *DestinationClient, s Scope) []error {
+			return crudCalls(c.Destinations(s), Destination{Name: "name1", Type: HTTPDestination})
+		}},
+		{SubaccountScope, "/subaccountCertificates", func(c *DestinationClient, s Scope) []error {
+			return crudCalls(c.Certificates(s), Certificate{Name: "name1"})
+		}},
+		{InstanceScope, "/instanceCertificates", func(c *DestinationClient, s Scope) []error {
+			return crudCalls(c.Certificates(s), Certificate{Name: "name1"})
+		}},
+		{SubaccountScope, "/subaccountDestinationFragments", func(c *DestinationClient, s Scope) []error {
+			return crudCalls(c.Fragments(s), Fragment{Name: "name1"})
+		}},
+		{InstanceScope, "/instanceDestinationFragments", func(c *DestinationClient, s Scope) []error {
+			return crudCalls(c.Fragments(s), Fragment{Name: "name1"})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.collection, func(t *testing.T) {
+			client, requests := recordingService(t)
+			for _, err := range tt.calls(client, tt.scope) {
+				if err != nil {
+					t.Error(err)
+				}
+			}
+			want := []string{
+				"GET " + tt.collection,
+				"GET " + tt.collection,
+				"GET " + tt.collection,
+				"GET " + tt.collection + "/name1",
+				"POST " + tt.collection,
+			}
+			if !strings.HasSuffix(tt.collection, "Certificates") {
+				want = append(want, "PUT "+tt.collection)
+			}
+			want = append(want, "DELETE "+tt.collection+"/name1")
+			if !slices.Equal(*requests, want) {
+				t.Errorf("got requests %q, want %q", *requests, want)
+			}
+		})
+	}
+}
+
+func TestLegacyMethodPaths(t *testing.T) {
+	client, requests := recordingService(t)
+	dest := Destination{Name: "name1", Type: HTTPDestination}
+	cert := Certificate{Name: "name1"}
+	fragment := Fragment{Name: "name1"}
+
+	client.GetSubaccountDestinations()
+	client.CreateSubaccountDestination(dest)
+	client.UpdateSubaccountDestination(dest)
+	client.GetSubaccountDestination("name1")
+	client.DeleteSubaccountDestination("name1")
+	client.GetSubaccountCertificates()
+	client.CreateSubaccountCertificate(cert)
+	client.GetSubaccountCertificate("name1")
+	client.DeleteSubaccountCertificate("name1")
+	client.GetSubaccountFragments()
+	client.CreateSubaccountFragment(fragment)
+	client.UpdateSubaccountFragment(fragment)
+	client.GetSubaccountFragment("name1")
+	client.DeleteSubaccountFragment("name1")
+	client.GetInstanceDestinations()
+	client.CreateInstanceDestination(dest)
+	client.UpdateInstanceDestination(dest)
+	client.GetInstanceDestination("name1")
+	client.DeleteInstanceDestination("name1")
+	client.GetInstanceCertificates()
+	client.CreateInstanceCertificate(cert)
+	client.GetInstanceCertificate("name1")
+	client.DeleteInstanceCertificate("name1")
+	client.GetInstanceFragments()
+	client.CreateInstanceFragment(fragment)
+	client.UpdateInstanceFragment(fragment)
+	client.GetInstanceFragment("name1")
+	client.DeleteInstanceFragment("name1")
+
+	var want []string
+	for _, scope := range []string{"subaccount", "instance"} {
+		for _, collection := range []string{"Destinations", "Certificates", "DestinationFragments"} {
+			path := "/" + scope + collection
+			want = append(want, "GET "+path, "POST "+path)
+			if collection != "Certificates" {
+				want = append(want, "PUT "+path)
+			}
+			want = append(want, "GET "+path+"/name1", "DELETE "+path+"/name1")
+		}
+	}
+	if !slices.Equal(*requests, want) {
+		t.Errorf("got requests\n%q\nwant\n%q", *requests, want)
+	}
+}
+
+func TestCertificatesHaveNoUpdate(t *testing.T) {
+	client, _ := recordingService(t)
+	for _, scope := range []Scope{SubaccountScope, InstanceScope} {
+		if _, ok := client.Certificates(scope).(interface {
+			Update(context.Context, Certificate) (AffectedRecords, error)
+		}); ok {
+			t.Errorf("the %s certificates must not provide Update, the service does not support it", scope)
+		}
+	}
+}
+
+func TestUnknownScope(t *testing.T) {
+	client, requests := recordingService(t)
+	for _, err := range crudCalls(client.Destinations(Scope("global")), Destination{Name: "name1"}) {
+		if err == nil || !strings.Contains(err.Error(), `unknown scope "global"`) {
+			t.Errorf("expected an unknown scope error, got %v", err)
+		}
+	}
+	if len(*requests) != 0 {
+		t.Errorf("expected no requests, got %q", *requests)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Service bindings mounted as directories, following the servicebinding.io specification.
+// Each binding is a directory below the root, containing one file per value. Bindings created by the
+// SAP BTP service operator also contain a .metadata file describing which values are credentials or
+// metadata, and which values are encoded as JSON.
+
+const serviceBindingMetadataFile = ".metadata"
+
+// serviceBindingMetadata is the content of the .metadata file of a service binding directory
+type serviceBindingMetadata struct {
+	MetaDataProperties   []serviceBindingProperty `json:"metaDataProperties"`
+	CredentialProperties []serviceBindingProperty `json:"credentialProperties"`
+}
+
+type serviceBindingProperty struct {
+	Name   string `json:"name"`
+	Format string `json:"format"`
+	// The value is a JSON object holding all the credentials
+	Container bool `json:"container"`
+}
+
+// readValue reads the file of a property, decoding it according to its format
+func (p serviceBindingProperty) readValue(dir string) (interface{}, error) {
+	data, err := os.ReadFile(filepath.Join(dir, p.Name))
+	if err != nil {
+		return nil, err
+	}
+	if p.Format != "json" {
+		return strings.TrimSpace(string(data)), nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filepath.Join(dir, p.Name), err)
+	}
+	return value, nil
+}
+
+// readServiceBinding reads a single service binding directory
+func readServiceBinding(dir string) (binding, error) {
+	b := binding{
+		Name:        filepath.Base(dir),
+		Credentials: map[string]interface{}{},
+	}
+	metadataFile, err := os.ReadFile(filepath.Join(dir, serviceBindingMetadataFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return readPlainServiceBinding(dir, b)
+	}
+	if err != nil {
+		return b, err
+	}
+	var metadata serviceBindingMetadata
+	if err := json.Unmarshal(metadataFile, &metadata); err != nil {
+		return b, fmt.Errorf("parsing %s: %w", filepath.Join(dir, serviceBindingMetadataFile), err)
+	}
+
+	for _, p := range metadata.MetaDataProperties {
+		value, err := p.readValue(dir)
+		if err != nil {
+			return b, err
+		}
+		switch p.Name {
+		case "instance_name":
+			b.InstanceName, _ = value.(string)
+		case "label":
+			b.Label, _ = value.(string)
+		case "type":
+			if b.Label == "" {
+				b.Label, _ = value.(string)
+			}
+		case "tags":
+			b.Tags = stringSlice(value)
+		}
+	}
+	for _, p := range metadata.CredentialProperties {
+		value, err := p.readValue(dir)
+		if err != nil {
+			return b, err
+		}
+		if container, ok := value.(map[string]interface{}); ok && p.Container {
+			for k, v := range container {
+				b.Credentials[k] = v
+			}
+			continue
+		}
+		b.Credentials[p.Name] = value
+	}
+	return b, nil
+}
+
+// readPlainServiceBinding reads a binding directory without a .metadata file. Every file is a text credential,
+// and the type file provides the label of the binding.
+func readPlainServiceBinding(dir string, b binding) (binding, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return b, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		value, err := serviceBindingProperty{Name: entry.Name()}.readValue(dir)
+		if err != nil {
+			return b, err
+		}
+		if entry.Name() == "type" {
+			b.Label = value.(string)
+			continue
+		}
+		b.Credentials[entry.Name()] = value
+	}
+	return b, nil
+}
+
+func stringSlice(value interface{}) []string {
+	values, _ := value.([]interface{})
+	retval := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			retval = append(retval, s)
+		}
+	}
+	return retval
+}
+
+// readServiceBindings reads all the service binding directories below root
+func readServiceBindings(root string) ([]binding, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var bindings []binding
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		// Kubernetes mounts secrets through symbolic links, so entries are checked with Stat
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		b, err := readServiceBinding(dir)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings, nil
+}
+
+// ParseServiceBindingDir builds a DestinationClientConfiguration from service bindings mounted below root, as described
+// by the servicebinding.io specification. By default the single binding of type DestinationServiceLabel is used. When
+// several bindings are present, pass BindingOption values to select one of them. The binding name is the name of the
+// binding directory, or the instance_name value provided by the .metadata file.
+func ParseServiceBindingDir(root string, opts ...BindingOption) (DestinationClientConfiguration, error) {
+	bindings, err := readServiceBindings(root)
+	if err != nil {
+		return DestinationClientConfiguration{}, err
+	}
+	b, err := newBindingFilter(DestinationServiceLabel, opts).selectBinding(bindings)
+	if err != nil {
+		return DestinationClientConfiguration{}, err
+	}
+	return b.clientConfiguration()
+}
+
+// NewClientFromServiceBinding creates a new DestinationClient configured from the Destination service binding mounted
+// below the directory named by the SERVICE_BINDING_ROOT environment variable
+func NewClientFromServiceBinding(opts ...BindingOption) (*DestinationClient, error) {
+	root, ok := os.LookupEnv("SERVICE_BINDING_ROOT")
+	if !ok {
+		return nil, errors.New("SERVICE_BINDING_ROOT is not set")
+	}
+	conf, err := ParseServiceBindingDir(root, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conf)
+}
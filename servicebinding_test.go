@@ -0,0 +1,72 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseServiceBindingDir(t *testing.T) {
+	tests := []struct {
+		name     string
+		root     string
+		opts     []BindingOption
+		clientID string
+		secret   string
+		err      error
+	}{
+		{"ambiguous", "testdata/bindings", nil, "", "", ErrAmbiguousBinding},
+		{"plain by directory", "testdata/bindings", []BindingOption{WithBindingName("plain-destination")}, "client-plain", "secret-plain", nil},
+		{"metadata by instance name", "testdata/bindings", []BindingOption{WithBindingName("my-destination-instance")}, "client-operator", "secret-operator", nil},
+		{"metadata by tag", "testdata/bindings", []BindingOption{WithBindingTag("backend")}, "client-operator", "secret-operator", nil},
+		{"json container", "testdata/bindings-container", nil, "client-container", "secret-container", nil},
+		{"not found", "testdata/bindings-container", []BindingOption{WithBindingTag("backend")}, "", "", ErrBindingNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := ParseServiceBindingDir(tt.root, tt.opts...)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if conf.ClientID != tt.clientID || conf.ClientSecret != tt.secret {
+				t.Errorf("got credentials %q/%q, want %q/%q", conf.ClientID, conf.ClientSecret, tt.clientID, tt.secret)
+			}
+		})
+	}
+}
+
+func TestReadServiceBindingMetadata(t *testing.T) {
+	b, err := readServiceBinding("testdata/bindings/operator-destination")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Label != "destination" || b.InstanceName != "my-destination-instance" || len(b.Tags) != 4 {
+		t.Errorf("unexpected binding metadata %#v", b)
+	}
+	uaa, ok := b.Credentials["uaa"].(map[string]interface{})
+	if !ok || uaa["clientid"] != "nested" {
+		t.Errorf("expected the uaa credential to be decoded as JSON, got %#v", b.Credentials["uaa"])
+	}
+}
+
+func TestNewClientFromServiceBinding(t *testing.T) {
+	t.Setenv("SERVICE_BINDING_ROOT", "testdata/bindings-container")
+	if _, err := NewClientFromServiceBinding(); err != nil {
+		t.Fatal(err)
+	}
+}
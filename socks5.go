@@ -0,0 +1,209 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// SOCKS5 protocol values, see RFC 1928. The Connectivity service adds the JWT authentication method
+const (
+	socks5Version        = 0x05
+	socks5JWTAuth        = 0x80
+	socks5JWTAuthVersion = 0x01
+	socks5Connect        = 0x01
+	socks5IPv4           = 0x01
+	socks5DomainName     = 0x03
+	socks5IPv6           = 0x04
+)
+
+var socks5Replies = map[byte]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// SOCKS5Dialer opens TCP connections to on-premise systems through the SOCKS5 proxy of the Connectivity service.
+// The proxy is authenticated with the Connectivity service token, and the Cloud Connector is selected by the location ID.
+// DialContext matches the signature of net.Dialer.DialContext, so the dialer can be plugged into SMTP and LDAP libraries.
+type SOCKS5Dialer struct {
+	proxy      *ConnectivityProxy
+	locationID string
+	// Dialer opens the connection to the proxy
+	Dialer net.Dialer
+}
+
+// SOCKS5Dialer returns a dialer for reaching the on-premise system of the destination, using its LocationID property
+func (p *ConnectivityProxy) SOCKS5Dialer(result DestinationLookupResult) (*SOCKS5Dialer, error) {
+	if p.socks5Address == "" {
+		return nil, fmt.Errorf("%w: SOCKS5ProxyPort is required", ErrInvalidConfiguration)
+	}
+	return &SOCKS5Dialer{
+		proxy:      p,
+		locationID: result.Destination.Properties[LocationIDProperty],
+	}, nil
+}
+
+// Dial connects to the address through the proxy
+func (d *SOCKS5Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to the address through the proxy, using ctx for fetching the token and for the handshake
+func (d *SOCKS5Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("socks5: network %q is not supported", network)
+	}
+	host, portValue, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("socks5: %w", err)
+	}
+	port, err := strconv.ParseUint(portValue, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("socks5: invalid port %q", portValue)
+	}
+	token, err := d.proxy.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching connectivity token: %w", err)
+	}
+
+	conn, err := d.Dialer.DialContext(ctx, "tcp", d.proxy.socks5Address)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// Interrupt the handshake when ctx is canceled
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	err = d.handshake(conn, token, host, uint16(port))
+	if !stop() {
+		err = errors.Join(err, ctx.Err())
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// handshake negotiates the JWT authentication method and connects to host and port
+func (d *SOCKS5Dialer) handshake(conn net.Conn, token string, host string, port uint16) error {
+	reply := make([]byte, 2)
+
+	if _, err := conn.Write([]byte{socks5Version, 1, socks5JWTAuth}); err != nil {
+		return fmt.Errorf("socks5: %w", err)
+	}
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("socks5: reading method selection: %w", err)
+	}
+	if reply[0] != socks5Version || reply[1] != socks5JWTAuth {
+		return fmt.Errorf("socks5: proxy did not accept JWT authentication (version %#x, method %#x)", reply[0], reply[1])
+	}
+
+	locationID := base64.StdEncoding.EncodeToString([]byte(d.locationID))
+	if len(locationID) > 255 {
+		return fmt.Errorf("socks5: location ID %q is too long", d.locationID)
+	}
+	auth := make([]byte, 0, 1+4+len(token)+1+len(locationID))
+	auth = append(auth, socks5JWTAuthVersion)
+	auth = binary.BigEndian.AppendUint32(auth, uint32(len(token)))
+	auth = append(auth, token...)
+	auth = append(auth, byte(len(locationID)))
+	auth = append(auth, locationID...)
+	if _, err := conn.Write(auth); err != nil {
+		return fmt.Errorf("socks5: %w", err)
+	}
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("socks5: reading authentication status: %w", err)
+	}
+	if reply[0] != socks5JWTAuthVersion || reply[1] != 0x00 {
+		return fmt.Errorf("socks5: authentication failed with status %#x", reply[1])
+	}
+
+	request := []byte{socks5Version, socks5Connect, 0x00}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("socks5: host name %q is too long", host)
+		}
+		request = append(request, socks5DomainName, byte(len(host)))
+		request = append(request, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(request, socks5IPv4)
+		request = append(request, ip4...)
+	} else {
+		request = append(request, socks5IPv6)
+		request = append(request, ip.To16()...)
+	}
+	request = binary.BigEndian.AppendUint16(request, port)
+	if _, err := conn.Write(request); err != nil {
+		return fmt.Errorf("socks5: %w", err)
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("socks5: reading connect reply: %w", err)
+	}
+	if header[0] != socks5Version {
+		return fmt.Errorf("socks5: unexpected reply version %#x", header[0])
+	}
+	if header[1] != 0x00 {
+		message, ok := socks5Replies[header[1]]
+		if !ok {
+			message = fmt.Sprintf("reply %#x", header[1])
+		}
+		return fmt.Errorf("socks5: connecting to %s: %s", net.JoinHostPort(host, strconv.Itoa(int(port))), message)
+	}
+	// Skip the bound address and port
+	var skip int
+	switch header[3] {
+	case socks5IPv4:
+		skip = net.IPv4len + 2
+	case socks5IPv6:
+		skip = net.IPv6len + 2
+	case socks5DomainName:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return fmt.Errorf("socks5: reading connect reply: %w", err)
+		}
+		skip = int(length[0]) + 2
+	default:
+		return fmt.Errorf("socks5: unexpected address type %#x", header[3])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, skip)); err != nil {
+		return fmt.Errorf("socks5: reading connect reply: %w", err)
+	}
+	return nil
+}
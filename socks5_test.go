@@ -0,0 +1,192 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// socks5StandIn accepts a single connection, checks the handshake and echoes a line back. The authStatus is sent in
+// reply to the JWT authentication.
+func socks5StandIn(t *testing.T, authStatus byte) (net.Listener, <-chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	done := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer conn.Close()
+		done <- serveSOCKS5(conn, authStatus)
+	}()
+	return listener, done
+}
+
+func serveSOCKS5(conn net.Conn, authStatus byte) error {
+	expect := func(want []byte) error {
+		got := make([]byte, len(want))
+		if _, err := io.ReadFull(conn, got); err != nil {
+			return err
+		}
+		if !bytes.Equal(got, want) {
+			return errors.New("unexpected handshake bytes " + base64.StdEncoding.EncodeToString(got))
+		}
+		return nil
+	}
+
+	if err := expect([]byte{0x05, 0x01, 0x80}); err != nil {
+		return err
+	}
+	conn.Write([]byte{0x05, 0x80})
+
+	token := "socks-token"
+	location := base64.StdEncoding.EncodeToString([]byte("berlin"))
+	auth := []byte{0x01, 0x00, 0x00, 0x00, byte(len(token))}
+	auth = append(auth, token...)
+	auth = append(auth, byte(len(location)))
+	auth = append(auth, location...)
+	if err := expect(auth); err != nil {
+		return err
+	}
+	conn.Write([]byte{0x01, authStatus})
+	if authStatus != 0x00 {
+		return nil
+	}
+
+	host := "mail.corp.local"
+	connect := []byte{0x05, 0x01, 0x00, 0x03, byte(len(host))}
+	connect = append(connect, host...)
+	connect = append(connect, 0x00, 0x19)
+	if err := expect(connect); err != nil {
+		return err
+	}
+	conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write([]byte(line))
+	return err
+}
+
+func newSOCKS5Dialer(t *testing.T, listener net.Listener) *SOCKS5Dialer {
+	t.Helper()
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy, err := NewConnectivityProxy(ConnectivityConfiguration{
+		ProxyHost:       host,
+		SOCKS5ProxyPort: port,
+		TokenSource:     oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "socks-token"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialer, err := proxy.SOCKS5Dialer(DestinationLookupResult{
+		Destination: Destination{Name: "mail", Type: MailDestination, Properties: map[string]string{
+			ProxyTypeProperty:  OnPremiseProxy,
+			LocationIDProperty: "berlin",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dialer
+}
+
+func TestSOCKS5Dialer(t *testing.T) {
+	listener, done := socks5StandIn(t, 0x00)
+	dialer := newSOCKS5Dialer(t, listener)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := dialer.DialContext(ctx, "tcp", "mail.corp.local:25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("EHLO client\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "EHLO client\n" {
+		t.Errorf("unexpected echo %q", line)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSOCKS5DialerAuthenticationFailure(t *testing.T) {
+	listener, done := socks5StandIn(t, 0x01)
+	dialer := newSOCKS5Dialer(t, listener)
+
+	_, err := dialer.Dial("tcp", "mail.corp.local:25")
+	if err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Errorf("expected an authentication error, got %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSOCKS5DialerErrors(t *testing.T) {
+	proxy, err := NewConnectivityProxy(ConnectivityConfiguration{
+		ProxyHost:   "127.0.0.1",
+		ProxyPort:   "20003",
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "socks-token"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := proxy.SOCKS5Dialer(DestinationLookupResult{}); !errors.Is(err, ErrInvalidConfiguration) {
+		t.Errorf("expected ErrInvalidConfiguration without a SOCKS5 port, got %v", err)
+	}
+
+	listener, _ := socks5StandIn(t, 0x00)
+	dialer := newSOCKS5Dialer(t, listener)
+	if _, err := dialer.Dial("udp", "mail.corp.local:25"); err == nil {
+		t.Error("expected an error for a UDP network")
+	}
+	if _, err := dialer.Dial("tcp", "mail.corp.local"); err == nil {
+		t.Error("expected an error for an address without a port")
+	}
+}
@@ -0,0 +1,247 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var (
+	// ErrUnsupportedKeyStore is returned for keystore and truststore formats that cannot be decoded, e.g. JKS or
+	// PEM keystores with encrypted keys
+	ErrUnsupportedKeyStore = errors.New("unsupported keystore format")
+	// ErrIncorrectKeyStorePassword is returned when a keystore or truststore cannot be decrypted with the configured password
+	ErrIncorrectKeyStorePassword = errors.New("incorrect keystore password")
+)
+
+// TLSConfig builds the TLS configuration for calling the system behind the destination.
+// The client certificate is loaded from the keystore named by the KeyStoreLocation property, and the server is verified
+// against the system roots and the certificates of the truststore named by the TrustStoreLocation property. Keystores
+// and truststores are taken from the certificates returned by Find, and may be PKCS#12 (.p12, .pfx) or PEM (.pem, .crt,
+// .cer) files; truststores may also be DER encoded certificates (.der). Server verification is disabled if the TrustAll
+// property is true. With the BrowserCompatible HostnameVerifier, a wildcard in the server certificate also matches host
+// names with several labels in its place.
+func (r DestinationLookupResult) TLSConfig() (*tls.Config, error) {
+	props := r.Destination.Properties
+	conf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if location := props[KeyStoreLocationProperty]; location != "" {
+		content, err := r.certificateContent(location)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := decodeKeyStore(location, content, props[KeyStorePasswordProperty])
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	if trustAll := props[TrustAllProperty]; trustAll != "" {
+		insecure, err := strconv.ParseBool(trustAll)
+		if err != nil {
+			return nil, fmt.Errorf("destination %q has an invalid %s property %q", r.Destination.Name, TrustAllProperty, trustAll)
+		}
+		conf.InsecureSkipVerify = insecure
+	}
+
+	browserCompatible := false
+	switch verifier := props[HostnameVerifierProperty]; verifier {
+	case "", StrictHostnameVerifier:
+		// crypto/tls matches a wildcard against a single label only, as the Strict verifier does
+	case BrowserCompatibleHostnameVerifier:
+		browserCompatible = true
+	default:
+		return nil, fmt.Errorf("destination %q has an unsupported %s property %q", r.Destination.Name, HostnameVerifierProperty, verifier)
+	}
+
+	if location := props[TrustStoreLocationProperty]; location != "" && !conf.InsecureSkipVerify {
+		content, err := r.certificateContent(location)
+		if err != nil {
+			return nil, err
+		}
+		certs, err := decodeTrustStore(location, content, props[TrustStorePasswordProperty])
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, cert := range certs {
+			pool.AddCert(cert)
+		}
+		conf.RootCAs = pool
+	}
+	if browserCompatible && !conf.InsecureSkipVerify {
+		// The chain and host name are verified by verifyBrowserCompatible instead
+		conf.InsecureSkipVerify = true
+		conf.VerifyConnection = verifyBrowserCompatible(conf.RootCAs)
+	}
+	return conf, nil
+}
+
+// verifyBrowserCompatible verifies the server certificate chain against roots, or the system roots if nil, and
+// matches the server name as the BrowserCompatible verifier does, where a wildcard also matches several labels
+func verifyBrowserCompatible(roots *x509.CertPool) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("tls: server did not send a certificate")
+		}
+		opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+		for _, cert := range cs.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		leaf := cs.PeerCertificates[0]
+		if _, err := leaf.Verify(opts); err != nil {
+			return err
+		}
+		err := leaf.VerifyHostname(cs.ServerName)
+		if err == nil {
+			return nil
+		}
+		host := strings.ToLower(strings.TrimSuffix(cs.ServerName, "."))
+		for _, name := range leaf.DNSNames {
+			if matchWildcardLabels(strings.ToLower(name), host) {
+				return nil
+			}
+		}
+		return err
+	}
+}
+
+// matchWildcardLabels reports whether host matches a wildcard pattern such as *.example.com, with the wildcard
+// standing for one or more labels. The pattern needs at least two labels after the wildcard.
+func matchWildcardLabels(pattern string, host string) bool {
+	suffix, ok := strings.CutPrefix(pattern, "*")
+	if !ok || strings.Count(suffix, ".") < 2 || !strings.HasPrefix(suffix, ".") {
+		return false
+	}
+	return len(host) > len(suffix) && strings.HasSuffix(host, suffix)
+}
+
+// HTTPClient returns an HTTP client that uses the TLS configuration returned by TLSConfig
+func (r DestinationLookupResult) HTTPClient() (*http.Client, error) {
+	conf, err := r.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = conf
+	return &http.Client{Transport: transport}, nil
+}
+
+// certificateContent returns the decoded content of the named certificate returned by Find
+func (r DestinationLookupResult) certificateContent(name string) ([]byte, error) {
+	for _, cert := range r.Certificates {
+		if cert.Name != name {
+			continue
+		}
+		content, err := base64.StdEncoding.DecodeString(cert.Content)
+		if err != nil {
+			return nil, fmt.Errorf("decoding certificate %q: %w", name, err)
+		}
+		return content, nil
+	}
+	return nil, fmt.Errorf("certificate %q of destination %q was not returned by the service", name, r.Destination.Name)
+}
+
+// decodeKeyStore decodes a client certificate with its private key
+func decodeKeyStore(name string, content []byte, password string) (tls.Certificate, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".p12", ".pfx":
+		key, cert, caCerts, err := pkcs12.DecodeChain(content, password)
+		if err != nil {
+			return tls.Certificate{}, keyStoreError(name, err)
+		}
+		tlsCert := tls.Certificate{
+			Certificate: [][]byte{cert.Raw},
+			PrivateKey:  key,
+			Leaf:        cert,
+		}
+		for _, ca := range caCerts {
+			tlsCert.Certificate = append(tlsCert.Certificate, ca.Raw)
+		}
+		return tlsCert, nil
+	case ".pem":
+		// The password is not needed for unencrypted keys, and encrypted ones cannot be decoded
+		for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type == "ENCRYPTED PRIVATE KEY" || block.Headers["Proc-Type"] == "4,ENCRYPTED" {
+				return tls.Certificate{}, fmt.Errorf("%w: keystore %q has an encrypted PEM key", ErrUnsupportedKeyStore, name)
+			}
+		}
+		cert, err := tls.X509KeyPair(content, content)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("decoding keystore %q: %w", name, err)
+		}
+		return cert, nil
+	}
+	return tls.Certificate{}, fmt.Errorf("%w: keystore %q", ErrUnsupportedKeyStore, name)
+}
+
+// decodeTrustStore decodes the trusted certificates of a truststore
+func decodeTrustStore(name string, content []byte, password string) ([]*x509.Certificate, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".p12", ".pfx":
+		certs, err := pkcs12.DecodeTrustStore(content, password)
+		if err != nil {
+			return nil, keyStoreError(name, err)
+		}
+		return certs, nil
+	case ".pem", ".crt", ".cer", ".der":
+		if block, _ := pem.Decode(content); block == nil {
+			cert, err := x509.ParseCertificate(content)
+			if err != nil {
+				return nil, fmt.Errorf("decoding truststore %q: %w", name, err)
+			}
+			return []*x509.Certificate{cert}, nil
+		}
+		var certs []*x509.Certificate
+		for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("decoding truststore %q: %w", name, err)
+			}
+			certs = append(certs, cert)
+		}
+		return certs, nil
+	}
+	return nil, fmt.Errorf("%w: truststore %q", ErrUnsupportedKeyStore, name)
+}
+
+func keyStoreError(name string, err error) error {
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+		return fmt.Errorf("%w: %q", ErrIncorrectKeyStorePassword, name)
+	}
+	return fmt.Errorf("decoding %q: %w", name, err)
+}
@@ -0,0 +1,315 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// newTestKeyStore returns a base64 encoded PKCS#12 keystore holding a new client certificate
+func newTestKeyStore(t *testing.T, commonName string, password string) string {
+	t.Helper()
+	certPEM, keyPEM := newTestCertificate(t, commonName)
+	pair, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pfx, err := pkcs12.Modern.Encode(pair.PrivateKey, pair.Leaf, nil, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(pfx)
+}
+
+func TestTLSConfigKeyStore(t *testing.T) {
+	certPEM, keyPEM := newTestCertificate(t, "pem-client")
+
+	tests := []struct {
+		name     string
+		location string
+		content  string
+		password string
+		wantCN   string
+		wantErr  error
+	}{
+		{"pkcs12", "client.p12", newTestKeyStore(t, "p12-client", "secret"), "secret", "p12-client", nil},
+		{"pfx", "client.PFX", newTestKeyStore(t, "pfx-client", "secret"), "secret", "pfx-client", nil},
+		{"pem", "client.pem", base64.StdEncoding.EncodeToString([]byte(certPEM + keyPEM)), "", "pem-client", nil},
+		{"pem with password", "client.pem", base64.StdEncoding.EncodeToString([]byte(certPEM + keyPEM)), "secret", "pem-client", nil},
+		{"encrypted pem", "client.pem", base64.StdEncoding.EncodeToString([]byte(certPEM + string(pem.EncodeToMemory(&pem.Block{
+			Type:    "RSA PRIVATE KEY",
+			Headers: map[string]string{"Proc-Type": "4,ENCRYPTED", "DEK-Info": "AES-128-CBC,00112233445566778899AABBCCDDEEFF"},
+			Bytes:   []byte("encrypted"),
+		})))), "secret", "", ErrUnsupportedKeyStore},
+		{"encrypted pkcs8 pem", "client.pem", base64.StdEncoding.EncodeToString([]byte(certPEM + string(pem.EncodeToMemory(&pem.Block{
+			Type:  "ENCRYPTED PRIVATE KEY",
+			Bytes: []byte("encrypted"),
+		})))), "secret", "", ErrUnsupportedKeyStore},
+		{"wrong password", "client.p12", newTestKeyStore(t, "p12-client", "secret"), "wrong", "", ErrIncorrectKeyStorePassword},
+		{"jks", "client.jks", base64.StdEncoding.EncodeToString([]byte("not a keystore")), "secret", "", ErrUnsupportedKeyStore},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := DestinationLookupResult{
+				Destination: Destination{
+					Name: "mtls",
+					Properties: map[string]string{
+						AuthenticationProperty:   ClientCertificateAuthentication,
+						KeyStoreLocationProperty: tt.location,
+						KeyStorePasswordProperty: tt.password,
+					},
+				},
+				Certificates: []Certificate{{Name: tt.location, Type: "CERTIFICATE", Content: tt.content}},
+			}
+			conf, err := result.TLSConfig()
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(conf.Certificates) != 1 {
+				t.Fatalf("expected a client certificate, got %d", len(conf.Certificates))
+			}
+			leaf, err := x509.ParseCertificate(conf.Certificates[0].Certificate[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if leaf.Subject.CommonName != tt.wantCN {
+				t.Errorf("expected common name %q, got %q", tt.wantCN, leaf.Subject.CommonName)
+			}
+		})
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		properties   map[string]string
+		certificates []Certificate
+	}{
+		{"missing keystore", map[string]string{KeyStoreLocationProperty: "client.p12"}, nil},
+		{"invalid base64", map[string]string{KeyStoreLocationProperty: "client.p12"}, []Certificate{{Name: "client.p12", Content: "%%%"}}},
+		{"invalid TrustAll", map[string]string{TrustAllProperty: "maybe"}, nil},
+		{"unsupported HostnameVerifier", map[string]string{HostnameVerifierProperty: "AllowAll"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := DestinationLookupResult{
+				Destination:  Destination{Name: "mtls", Properties: tt.properties},
+				Certificates: tt.certificates,
+			}
+			if _, err := result.TLSConfig(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestTLSConfigTrustStore(t *testing.T) {
+	certPEM, _ := newTestCertificate(t, "server")
+	block, _ := pem.Decode([]byte(certPEM))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p12, err := pkcs12.Modern.EncodeTrustStore([]*x509.Certificate{cert}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		location string
+		content  []byte
+		password string
+	}{
+		{"pem", "trust.pem", []byte(certPEM), ""},
+		{"der", "trust.der", block.Bytes, ""},
+		{"pkcs12", "trust.p12", p12, "secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := DestinationLookupResult{
+				Destination: Destination{
+					Name: "mtls",
+					Properties: map[string]string{
+						TrustStoreLocationProperty: tt.location,
+						TrustStorePasswordProperty: tt.password,
+					},
+				},
+				Certificates: []Certificate{{Name: tt.location, Content: base64.StdEncoding.EncodeToString(tt.content)}},
+			}
+			conf, err := result.TLSConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if conf.RootCAs == nil {
+				t.Fatal("expected a trust pool")
+			}
+			if _, err := cert.Verify(x509.VerifyOptions{Roots: conf.RootCAs, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+				t.Errorf("expected the truststore certificate to be trusted: %v", err)
+			}
+		})
+	}
+}
+
+func TestTLSConfigTrustAll(t *testing.T) {
+	result := DestinationLookupResult{
+		Destination: Destination{
+			Name:       "insecure",
+			Properties: map[string]string{TrustAllProperty: "true", TrustStoreLocationProperty: "ignored.jks"},
+		},
+	}
+	conf, err := result.TLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !conf.InsecureSkipVerify {
+		t.Error("expected server verification to be disabled")
+	}
+}
+
+func TestLookupResultHTTPClient(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	serverPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	result := DestinationLookupResult{
+		Destination: Destination{
+			Name: "mtls",
+			Properties: map[string]string{
+				URLProperty:                server.URL,
+				AuthenticationProperty:     ClientCertificateAuthentication,
+				KeyStoreLocationProperty:   "client.p12",
+				KeyStorePasswordProperty:   "secret",
+				TrustStoreLocationProperty: "server.crt",
+			},
+		},
+		Certificates: []Certificate{
+			{Name: "client.p12", Content: newTestKeyStore(t, "destination-client", "secret")},
+			{Name: "server.crt", Content: base64.StdEncoding.EncodeToString(serverPEM)},
+		},
+	}
+	client, err := result.HTTPClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "destination-client" {
+		t.Errorf("expected the keystore certificate to be presented, got %q", body)
+	}
+}
+
+func TestTLSConfigHostnameVerifier(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "wildcard"},
+		DNSNames:              []string{"*.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	server.StartTLS()
+	defer server.Close()
+	serverPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	tests := []struct {
+		verifier string
+		url      string
+		wantErr  bool
+	}{
+		{StrictHostnameVerifier, "https://api.example.com", false},
+		{StrictHostnameVerifier, "https://eu.api.example.com", true},
+		{BrowserCompatibleHostnameVerifier, "https://api.example.com", false},
+		{BrowserCompatibleHostnameVerifier, "https://eu.api.example.com", false},
+		{BrowserCompatibleHostnameVerifier, "https://api.example.org", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.verifier+" "+tt.url, func(t *testing.T) {
+			result := DestinationLookupResult{
+				Destination: Destination{Name: "wildcard", Properties: map[string]string{
+					URLProperty:                tt.url,
+					HostnameVerifierProperty:   tt.verifier,
+					TrustStoreLocationProperty: "server.crt",
+				}},
+				Certificates: []Certificate{{Name: "server.crt", Content: base64.StdEncoding.EncodeToString(serverPEM)}},
+			}
+			client, err := result.HTTPClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.Transport.(*http.Transport).DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+			}
+			resp, err := client.Get(tt.url)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected an error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpirySkew is subtracted from token expiry times, so tokens are not sent just as they expire
+const tokenExpirySkew = 30 * time.Second
+
+type userTokenKey struct{}
+
+// ContextWithUserToken returns a context carrying the user token passed to Find by Transport
+func ContextWithUserToken(ctx context.Context, userToken string) context.Context {
+	return context.WithValue(ctx, userTokenKey{}, userToken)
+}
+
+// UserTokenFromContext returns the user token stored in ctx by ContextWithUserToken
+func UserTokenFromContext(ctx context.Context) (string, bool) {
+	userToken, ok := ctx.Value(userTokenKey{}).(string)
+	return userToken, ok
+}
+
+// Transport is an http.RoundTripper that sends requests to the system behind a destination.
+// The destination is resolved using Find. Requests are rewritten to the scheme, host and base path of the destination
+// URL property, with the path of the request appended to the base path, and the authentication headers returned by
+// Find are added. Lookups are reused until their authentication tokens expire or MaxAge passes, and concurrent requests
+// with the same options share a single lookup. Destinations with the OnPremise proxy type are sent through the
+// Connectivity service proxy.
+type Transport struct {
+	// Finder resolves the destination. Usually a *DestinationClient
+	Finder DestinationFinder
+	// DestinationName is the name of the destination
+	DestinationName string
+	// FindOptions are passed to Find. The user token is taken from the request context when available, see ContextWithUserToken
+	FindOptions FindOptions
+	// Base is the transport used for sending the rewritten requests. http.DefaultTransport is used if this is nil
+	Base http.RoundTripper
+	// Connectivity sends the requests of OnPremise destinations. Required for calling OnPremise destinations
+	Connectivity *ConnectivityProxy
+	// MaxAge limits how long a lookup is reused, also when its tokens do not expire. DefaultLookupMaxAge is used if zero
+	MaxAge time.Duration
+	// MaxLookups limits the number of lookups kept, one for every user token. DefaultMaxLookups is used if zero
+	MaxLookups int
+
+	mu      sync.Mutex
+	lookups map[string]transportLookup
+	pending map[string]*pendingLookup
+}
+
+// Defaults for the reuse of lookups by Transport
+const (
+	DefaultLookupMaxAge = 5 * time.Minute
+	DefaultMaxLookups   = 1000
+)
+
+// transportLookup is a lookup kept by Transport until expires
+type transportLookup struct {
+	result  DestinationLookupResult
+	expires time.Time
+}
+
+// pendingLookup is a lookup in progress, shared by the requests waiting for it
+type pendingLookup struct {
+	done   chan struct{}
+	result DestinationLookupResult
+	err    error
+	// canceled is set when the lookup failed because the context of the request running it was done
+	canceled bool
+}
+
+// NewTransport creates a Transport that sends requests to the named destination, resolved with finder
+func NewTransport(finder DestinationFinder, destinationName string) *Transport {
+	return &Transport{
+		Finder:          finder,
+		DestinationName: destinationName,
+	}
+}
+
+// lookup returns the lookup result for the destination, reusing a previous result as long as its tokens are valid.
+// The service is called without holding the lock, so a slow lookup only delays the requests waiting for the same result.
+// When the request running a shared lookup is canceled, the requests waiting for it run the lookup again.
+func (t *Transport) lookup(ctx context.Context) (DestinationLookupResult, error) {
+	opts := t.findOptions(ctx)
+	key := opts.cacheKey(t.DestinationName)
+
+	for {
+		t.mu.Lock()
+		now := time.Now()
+		for k, entry := range t.lookups {
+			if !now.Before(entry.expires) {
+				delete(t.lookups, k)
+			}
+		}
+		if entry, ok := t.lookups[key]; ok {
+			t.mu.Unlock()
+			return entry.result, nil
+		}
+		call, ok := t.pending[key]
+		if !ok {
+			break
+		}
+		t.mu.Unlock()
+		select {
+		case <-call.done:
+			if !call.canceled {
+				return call.result, call.err
+			}
+		case <-ctx.Done():
+			return DestinationLookupResult{}, ctx.Err()
+		}
+	}
+	call := &pendingLookup{done: make(chan struct{})}
+	if t.pending == nil {
+		t.pending = map[string]*pendingLookup{}
+	}
+	t.pending[key] = call
+	t.mu.Unlock()
+
+	call.result, call.err = t.find(ctx, opts)
+	call.canceled = call.err != nil && ctx.Err() != nil
+
+	t.mu.Lock()
+	delete(t.pending, key)
+	if call.err == nil {
+		t.store(key, call.result, time.Now())
+	}
+	t.mu.Unlock()
+	close(call.done)
+	return call.result, call.err
+}
+
+// find calls the service and checks the returned authentication tokens
+func (t *Transport) find(ctx context.Context, opts FindOptions) (DestinationLookupResult, error) {
+	result, err := t.Finder.FindWithOptions(ctx, t.DestinationName, opts)
+	if err != nil {
+		return result, err
+	}
+	if !opts.SkipTokenRetrieval {
+		if err := result.TokenError(); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// store keeps a lookup result until MaxAge passes or its tokens expire, evicting the lookups that expire first when
+// MaxLookups is reached. Must be called with t.mu held.
+func (t *Transport) store(key string, result DestinationLookupResult, now time.Time) {
+	maxAge, maxLookups := t.MaxAge, t.MaxLookups
+	if maxAge == 0 {
+		maxAge = DefaultLookupMaxAge
+	}
+	if maxLookups == 0 {
+		maxLookups = DefaultMaxLookups
+	}
+	expires := now.Add(maxAge)
+	if expiresAt := result.ExpiresAt(); !expiresAt.IsZero() && expiresAt.Add(-tokenExpirySkew).Before(expires) {
+		expires = expiresAt.Add(-tokenExpirySkew)
+	}
+	if !now.Before(expires) {
+		return
+	}
+	if t.lookups == nil {
+		t.lookups = map[string]transportLookup{}
+	}
+	for len(t.lookups) >= maxLookups {
+		first := ""
+		for k, entry := range t.lookups {
+			if first == "" || entry.expires.Before(t.lookups[first].expires) {
+				first = k
+			}
+		}
+		delete(t.lookups, first)
+	}
+	t.lookups[key] = transportLookup{result: result, expires: expires}
+}
+
+// findOptions returns the options passed to Find for a request with the given context
+func (t *Transport) findOptions(ctx context.Context) FindOptions {
+	opts := t.FindOptions
+	if userToken, ok := UserTokenFromContext(ctx); ok {
+		opts.UserToken = userToken
+	}
+	return opts
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	closeBody := func() {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+	}
+	result, err := t.lookup(req.Context())
+	if err != nil {
+		closeBody()
+		return nil, err
+	}
+	rewritten, err := rewriteRequest(req, result)
+	if err != nil {
+		closeBody()
+		return nil, err
+	}
+	if result.Destination.Properties[ProxyTypeProperty] == OnPremiseProxy {
+		if t.Connectivity == nil {
+			closeBody()
+			return nil, fmt.Errorf("destination %q is an OnPremise destination and requires a connectivity proxy", t.DestinationName)
+		}
+		return t.Connectivity.roundTrip(rewritten, result, t.findOptions(req.Context()).UserToken)
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(rewritten)
+}
+
+// rewriteRequest returns a copy of req addressed to the destination URL and carrying the destination authentication headers
+func rewriteRequest(req *http.Request, result DestinationLookupResult) (*http.Request, error) {
+	destinationURL := result.Destination.Properties[URLProperty]
+	if destinationURL == "" {
+		return nil, fmt.Errorf("destination %q has no %s property", result.Destination.Name, URLProperty)
+	}
+	target, err := url.Parse(destinationURL)
+	if err != nil {
+		return nil, fmt.Errorf("destination %q has an invalid %s property: %w", result.Destination.Name, URLProperty, err)
+	}
+	if target.Scheme == "" || target.Host == "" {
+		return nil, fmt.Errorf("destination %q %s property must be an absolute URL", result.Destination.Name, URLProperty)
+	}
+
+	rewritten := req.Clone(req.Context())
+	rewritten.URL.Scheme = target.Scheme
+	rewritten.URL.Host = target.Host
+	// The paths are joined in their escaped form, so that encoded characters such as %2F keep their meaning
+	escapedPath := joinURLPath(target.EscapedPath(), req.URL.EscapedPath())
+	path, err := url.PathUnescape(escapedPath)
+	if err != nil {
+		return nil, fmt.Errorf("destination %q has an invalid %s property: %w", result.Destination.Name, URLProperty, err)
+	}
+	rewritten.URL.Path = path
+	rewritten.URL.RawPath = escapedPath
+	if target.RawQuery != "" {
+		if req.URL.RawQuery == "" {
+			rewritten.URL.RawQuery = target.RawQuery
+		} else {
+			rewritten.URL.RawQuery = target.RawQuery + "&" + req.URL.RawQuery
+		}
+	}
+	rewritten.Host = ""
+	for _, token := range result.AuthTokens {
+		if token.Error != "" {
+			continue
+		}
+		key, value := token.Header()
+		rewritten.Header.Set(key, value)
+	}
+	return rewritten, nil
+}
+
+func joinURLPath(base, path string) string {
+	switch {
+	case path == "" || path == "/":
+		if base == "" {
+			return "/"
+		}
+		return base
+	case base == "":
+		return path
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(path, "/")
+}
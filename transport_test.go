@@ -0,0 +1,319 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubFinder returns lookup results built by lookup, counting the calls
+type stubFinder struct {
+	calls  int
+	opts   []FindOptions
+	lookup func(name string, opts FindOptions) (DestinationLookupResult, error)
+}
+
+func (f *stubFinder) Find(name string, userToken string) (DestinationLookupResult, error) {
+	return f.FindWithOptions(context.Background(), name, FindOptions{UserToken: userToken})
+}
+
+func (f *stubFinder) FindCtx(ctx context.Context, name string, userToken string) (DestinationLookupResult, error) {
+	return f.FindWithOptions(ctx, name, FindOptions{UserToken: userToken})
+}
+
+func (f *stubFinder) FindWithOptions(_ context.Context, name string, opts FindOptions) (DestinationLookupResult, error) {
+	f.calls++
+	f.opts = append(f.opts, opts)
+	return f.lookup(name, opts)
+}
+
+func TestTransport(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sap/opu/odata/sap/API/Products" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if got := r.URL.RawQuery; got != "sap-client=100&$top=1" {
+			t.Errorf("unexpected query %q", got)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer token-for-alice" {
+			t.Errorf("unexpected Authorization header %q", got)
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer backend.Close()
+
+	finder := &stubFinder{lookup: func(name string, opts FindOptions) (DestinationLookupResult, error) {
+		return DestinationLookupResult{
+			Destination: Destination{Name: name, Type: HTTPDestination, Properties: map[string]string{
+				URLProperty: backend.URL + "/sap/opu/odata/sap/API?sap-client=100",
+			}},
+			AuthTokens: []AuthToken{{
+				Type:       "bearer",
+				Value:      "token-for-" + opts.UserToken,
+				HTTPHeader: AuthTokenHeader{Key: "Authorization", Value: "Bearer token-for-" + opts.UserToken},
+				ExpiresAt:  time.Now().Add(time.Hour),
+			}},
+		}, nil
+	}}
+	client := &http.Client{Transport: NewTransport(finder, "backend")}
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequestWithContext(ContextWithUserToken(context.Background(), "alice"), http.MethodGet, "http://backend/Products?$top=1", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("unexpected status %d", resp.StatusCode)
+		}
+	}
+	if finder.calls != 1 || finder.opts[0].UserToken != "alice" {
+		t.Errorf("expected a single lookup with the user token, got %d %#v", finder.calls, finder.opts)
+	}
+}
+
+func TestTransportKeepsEncodedPath(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.EscapedPath(); got != "/odata/Products('a%2Fb')/items" {
+			t.Errorf("unexpected path %q", got)
+		}
+	}))
+	defer backend.Close()
+
+	finder := &stubFinder{lookup: func(name string, opts FindOptions) (DestinationLookupResult, error) {
+		return DestinationLookupResult{
+			Destination: Destination{Name: name, Properties: map[string]string{URLProperty: backend.URL + "/odata"}},
+		}, nil
+	}}
+	client := &http.Client{Transport: NewTransport(finder, "backend")}
+	resp, err := client.Get("http://backend/Products('a%2Fb')/items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestTransportRenewsExpiredTokens(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	finder := &stubFinder{lookup: func(name string, opts FindOptions) (DestinationLookupResult, error) {
+		return DestinationLookupResult{
+			Destination: Destination{Name: name, Properties: map[string]string{URLProperty: backend.URL}},
+			AuthTokens:  []AuthToken{{Type: "bearer", Value: "token", ExpiresAt: time.Now().Add(tokenExpirySkew / 2)}},
+		}, nil
+	}}
+	client := &http.Client{Transport: NewTransport(finder, "backend")}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get("http://backend/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if finder.calls != 2 {
+		t.Errorf("expected the expiring token to be renewed, got %d lookups", finder.calls)
+	}
+}
+
+func TestTransportTokenError(t *testing.T) {
+	finder := &stubFinder{lookup: func(name string, opts FindOptions) (DestinationLookupResult, error) {
+		return DestinationLookupResult{
+			Destination: Destination{Name: name, Properties: map[string]string{URLProperty: "https://backend.example.com"}},
+			AuthTokens:  []AuthToken{{Error: "token exchange failed"}},
+		}, nil
+	}}
+	client := &http.Client{Transport: NewTransport(finder, "backend")}
+	if _, err := client.Get("http://backend/"); !errors.Is(err, ErrTokenRetrieval) {
+		t.Errorf("expected ErrTokenRetrieval, got %v", err)
+	}
+}
+
+// blockingFinder blocks the lookups for blocked user tokens until release is closed, counting the calls per user token
+type blockingFinder struct {
+	stubFinder
+	url     string
+	blocked string
+	release chan struct{}
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (f *blockingFinder) FindWithOptions(_ context.Context, name string, opts FindOptions) (DestinationLookupResult, error) {
+	f.mu.Lock()
+	f.calls[opts.UserToken]++
+	f.mu.Unlock()
+	if opts.UserToken == f.blocked {
+		<-f.release
+	}
+	return DestinationLookupResult{Destination: Destination{Name: name, Properties: map[string]string{URLProperty: f.url}}}, nil
+}
+
+func (f *blockingFinder) count(userToken string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[userToken]
+}
+
+// cancelFinder blocks the first lookup until its context is done, and returns a result for the following lookups
+type cancelFinder struct {
+	stubFinder
+	started chan struct{}
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *cancelFinder) FindWithOptions(ctx context.Context, name string, _ FindOptions) (DestinationLookupResult, error) {
+	f.mu.Lock()
+	f.calls++
+	first := f.calls == 1
+	f.mu.Unlock()
+	if first {
+		close(f.started)
+		<-ctx.Done()
+		return DestinationLookupResult{}, ctx.Err()
+	}
+	return DestinationLookupResult{Destination: Destination{Name: name, Properties: map[string]string{URLProperty: "https://backend.example.com"}}}, nil
+}
+
+func TestTransportCanceledSharedLookup(t *testing.T) {
+	finder := &cancelFinder{started: make(chan struct{})}
+	transport := NewTransport(finder, "backend")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error)
+	go func() {
+		_, err := transport.lookup(ctx)
+		leader <- err
+	}()
+	<-finder.started
+	waiter := make(chan error)
+	go func() {
+		_, err := transport.lookup(context.Background())
+		waiter <- err
+	}()
+	// Let the second request wait for the pending lookup before canceling the first one
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the canceled request to fail with context.Canceled, got %v", err)
+	}
+	if err := <-waiter; err != nil {
+		t.Errorf("expected the waiting request to run its own lookup, got %v", err)
+	}
+	if finder.calls != 2 {
+		t.Errorf("expected 2 lookups, got %d", finder.calls)
+	}
+}
+
+func TestTransportConcurrentLookups(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	finder := &blockingFinder{url: backend.URL, blocked: "slow-user", release: make(chan struct{}), calls: map[string]int{}}
+	client := &http.Client{Transport: NewTransport(finder, "backend")}
+
+	get := func(userToken string) error {
+		req, err := http.NewRequestWithContext(ContextWithUserToken(context.Background(), userToken), http.MethodGet, "http://backend/", nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- get("slow-user")
+		}()
+	}
+	// The slow lookup must not block other users
+	done := make(chan error)
+	go func() { done <- get("fast-user") }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow lookup blocked the requests of another user")
+	}
+
+	close(finder.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if n := finder.count("slow-user"); n != 1 {
+		t.Errorf("expected concurrent requests to share a single lookup, got %d lookups", n)
+	}
+}
+
+func TestTransportLookupLimits(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	finder := &blockingFinder{url: backend.URL, calls: map[string]int{}}
+	transport := NewTransport(finder, "backend")
+	transport.MaxLookups = 2
+	client := &http.Client{Transport: transport}
+
+	get := func(userToken string) {
+		t.Helper()
+		req, _ := http.NewRequestWithContext(ContextWithUserToken(context.Background(), userToken), http.MethodGet, "http://backend/", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	for _, user := range []string{"alice", "bob", "carol", "carol"} {
+		get(user)
+	}
+	if n := len(transport.lookups); n != 2 {
+		t.Errorf("expected at most 2 lookups to be kept, got %d", n)
+	}
+	if n := finder.count("carol"); n != 1 {
+		t.Errorf("expected the lookup to be reused, got %d lookups", n)
+	}
+
+	transport.MaxAge = time.Nanosecond
+	get("dave")
+	get("dave")
+	if n := finder.count("dave"); n != 2 {
+		t.Errorf("expected lookups older than MaxAge to be renewed, got %d lookups", n)
+	}
+}
@@ -16,6 +16,11 @@ limitations under the License.
 
 package gosapcpdestinationclient
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // Types used by the RESTful API
 
 // DestinationType enumeration
@@ -41,14 +46,20 @@ const (
 
 	// Valid values for the authentication property
 
-	AppToAppSSOAuthentication               = "AppToAppSSO"
-	BasicAuthentication                     = "BasicAuthentication"
-	ClientCertificateAuthentication         = "ClientCertificateAuthentication"
-	NoAuthentication                        = "NoAuthentication"
-	OAuth2ClientCredentialsAuthentication   = "OAuth2ClientCredentials"
-	OAuth2SAMLBearerAssertionAuthentication = "OAuth2SAMLBearerAssertion"
-	OAuth2UserTokenExchangeAuthentication   = "OAuth2UserTokenExchange"
-	SAPAssetionSSOAuthentication            = "SAPAssertionSSO"
+	AppToAppSSOAuthentication                    = "AppToAppSSO"
+	BasicAuthentication                          = "BasicAuthentication"
+	ClientCertificateAuthentication              = "ClientCertificateAuthentication"
+	NoAuthentication                             = "NoAuthentication"
+	OAuth2AuthorizationCodeAuthentication        = "OAuth2AuthorizationCode"
+	OAuth2ClientCredentialsAuthentication        = "OAuth2ClientCredentials"
+	OAuth2JWTBearerAuthentication                = "OAuth2JWTBearer"
+	OAuth2PasswordAuthentication                 = "OAuth2Password"
+	OAuth2SAMLBearerAssertionAuthentication      = "OAuth2SAMLBearerAssertion"
+	OAuth2TechnicalUserPropagationAuthentication = "OAuth2TechnicalUserPropagation"
+	OAuth2UserTokenExchangeAuthentication        = "OAuth2UserTokenExchange"
+	PrincipalPropagationAuthentication           = "PrincipalPropagation"
+	SAMLAssertionAuthentication                  = "SAMLAssertion"
+	SAPAssetionSSOAuthentication                 = "SAPAssertionSSO"
 
 	// Property name for the destination ProxyType property
 	ProxyTypeProperty = "ProxyType"
@@ -74,12 +85,44 @@ const (
 
 	// Property name for the destination RepositoryPassword property
 	RepoPasswordProperty = "RepositoryPassword"
+
+	// Property name for the destination KeyStoreLocation property, the name of the certificate holding the client certificate
+	KeyStoreLocationProperty = "KeyStoreLocation"
+
+	// Property name for the destination KeyStorePassword property
+	KeyStorePasswordProperty = "KeyStorePassword"
+
+	// Property name for the destination TrustStoreLocation property, the name of the certificate holding the trusted certificates
+	TrustStoreLocationProperty = "TrustStoreLocation"
+
+	// Property name for the destination TrustStorePassword property
+	TrustStorePasswordProperty = "TrustStorePassword"
+
+	// Property name for the destination TrustAll property
+	TrustAllProperty = "TrustAll"
+
+	// Property name for the destination HostnameVerifier property
+	HostnameVerifierProperty = "HostnameVerifier"
+
+	// Valid values for the HostnameVerifier property
+	StrictHostnameVerifier            = "Strict"
+	BrowserCompatibleHostnameVerifier = "BrowserCompatible"
 )
 
 // ErrorMessage struct contains errors returned by the Destination API
 type ErrorMessage struct {
 	ErrorMessage string `json:"ErrorMessage"`
-	statusCode   int
+	// HTTP method of the failed request
+	Method string `json:"-"`
+	// URL path of the failed request
+	Path string `json:"-"`
+	// Raw body of the error response
+	Body []byte `json:"-"`
+	// Value of the x-correlationid header of the error response
+	CorrelationID string `json:"-"`
+	// Value of the x-vcap-request-id header of the error response
+	VCAPRequestID string `json:"-"`
+	statusCode    int
 }
 
 // Destination describes a single Destination
@@ -90,6 +133,19 @@ type Destination struct {
 	Type DestinationType
 	// Any properties defined on the destination
 	Properties map[string]string
+	// Properties whose values are not JSON strings, such as numbers, booleans or objects. They are kept as raw JSON
+	// so they are written back unchanged. A property in Properties takes precedence over one with the same name here.
+	RawProperties map[string]json.RawMessage
+}
+
+// Fragment describes a destination fragment, a named set of properties that is merged into a destination when finding it
+type Fragment struct {
+	// The name of the fragment
+	Name string
+	// Any properties defined on the fragment
+	Properties map[string]string
+	// Properties whose values are not JSON strings, kept as raw JSON as in Destination
+	RawProperties map[string]json.RawMessage
 }
 
 // Certificate describes a single certificate
@@ -108,6 +164,26 @@ type AuthToken struct {
 	Type string `json:"type"`
 	// Value of the authentication token
 	Value string `json:"value"`
+	// HTTP header to add to requests sent to the destination
+	HTTPHeader AuthTokenHeader `json:"http_header"`
+	// Lifetime of the token in seconds, or 0 if not provided
+	ExpiresIn int `json:"expires_in,omitempty"`
+	// Error reported by the service when retrieving the token failed
+	Error string `json:"error,omitempty"`
+	// Scope of the token, if provided
+	Scope string `json:"scope,omitempty"`
+	// Refresh token, if provided
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// Expiry time of the token, computed from ExpiresIn and the time of the lookup. Zero if ExpiresIn is not provided
+	ExpiresAt time.Time `json:"-"`
+}
+
+// AuthTokenHeader describes the HTTP header carrying an authentication token
+type AuthTokenHeader struct {
+	// Name of the header
+	Key string `json:"key"`
+	// Value of the header
+	Value string `json:"value"`
 }
 
 // Owner describes the level on which the destination is defined.
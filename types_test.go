@@ -0,0 +1,119 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestDestinationJSON(t *testing.T) {
+	data := `{"Name":"dest1","Type":"HTTP","URL":"https://example.com","Timeout":30,"Enabled":true,"Nested":{"a":[1,"b"]},"Empty":null}`
+	var dest Destination
+	if err := json.Unmarshal([]byte(data), &dest); err != nil {
+		t.Fatal(err)
+	}
+	want := Destination{
+		Name:       "dest1",
+		Type:       HTTPDestination,
+		Properties: map[string]string{URLProperty: "https://example.com"},
+		RawProperties: map[string]json.RawMessage{
+			"Timeout": json.RawMessage(`30`),
+			"Enabled": json.RawMessage(`true`),
+			"Nested":  json.RawMessage(`{"a":[1,"b"]}`),
+			"Empty":   json.RawMessage(`null`),
+		},
+	}
+	if !reflect.DeepEqual(dest, want) {
+		t.Errorf("got %#v, want %#v", dest, want)
+	}
+
+	encoded, err := json.Marshal(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, expected map[string]interface{}
+	if err := json.Unmarshal(encoded, &got); err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal([]byte(data), &expected)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("round trip changed the destination\n got %s\nwant %s", encoded, data)
+	}
+	if len(dest.Properties) != 1 {
+		t.Error("MarshalJSON must not modify the destination properties")
+	}
+}
+
+func TestDestinationJSONUnknownType(t *testing.T) {
+	var dest Destination
+	if err := json.Unmarshal([]byte(`{"Name":"dest1","Type":"FTP"}`), &dest); err != nil {
+		t.Fatal(err)
+	}
+	if dest.Type != DestinationType("FTP") {
+		t.Errorf("expected the unknown type to be kept, got %q", dest.Type)
+	}
+	if dest.Properties == nil || dest.RawProperties != nil {
+		t.Errorf("unexpected properties %#v", dest)
+	}
+	encoded, err := json.Marshal(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Name":"dest1","Type":"FTP"}`; string(encoded) != want {
+		t.Errorf("got %s, want %s", encoded, want)
+	}
+}
+
+func TestDestinationJSONPrecedence(t *testing.T) {
+	dest := Destination{
+		Name:          "dest1",
+		Type:          HTTPDestination,
+		Properties:    map[string]string{"Timeout": "60"},
+		RawProperties: map[string]json.RawMessage{"Timeout": json.RawMessage(`30`), "Name": json.RawMessage(`1`)},
+	}
+	encoded, err := json.Marshal(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Name":"dest1","Timeout":"60","Type":"HTTP"}`; string(encoded) != want {
+		t.Errorf("got %s, want %s", encoded, want)
+	}
+}
+
+func TestGetDestinationsWithNonStringProperties(t *testing.T) {
+	client, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[{"Name":"dest1","Type":"HTTP","Retries":3},{"Name":"dest2","Type":"TCP","Options":{"keepAlive":true}}]`)
+	}))
+	destinations, err := client.GetSubaccountDestinations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(destinations) != 2 {
+		t.Fatalf("expected 2 destinations, got %d", len(destinations))
+	}
+	if got := string(destinations[0].RawProperties["Retries"]); got != "3" {
+		t.Errorf("unexpected Retries %q", got)
+	}
+	if destinations[1].Type != "TCP" || string(destinations[1].RawProperties["Options"]) != `{"keepAlive":true}` {
+		t.Errorf("unexpected destination %#v", destinations[1])
+	}
+}
@@ -0,0 +1,218 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidDestination is wrapped by the errors returned for destinations that fail validation
+var ErrInvalidDestination = errors.New("invalid destination")
+
+// ValidationError describes a problem with a single destination property
+type ValidationError struct {
+	// Name of the property
+	Property string
+	// Description of the problem, including the property name
+	Message string
+}
+
+// Error implements error
+func (e ValidationError) Error() string {
+	return e.Message
+}
+
+// validationErrors collects the problems found while validating a destination
+type validationErrors []ValidationError
+
+func (v *validationErrors) add(property string, format string, args ...interface{}) {
+	*v = append(*v, ValidationError{Property: property, Message: fmt.Sprintf(format, args...)})
+}
+
+// require reports the property if its value is empty
+func (v *validationErrors) require(property string, value string) {
+	if value == "" {
+		v.add(property, "%s is required", property)
+	}
+}
+
+// err returns an error wrapping ErrInvalidDestination and all the problems, or nil if there are none
+func (v validationErrors) err(name string) error {
+	if len(v) == 0 {
+		return nil
+	}
+	errs := make([]error, 0, len(v))
+	for _, e := range v {
+		errs = append(errs, e)
+	}
+	return fmt.Errorf("%w %q: %w", ErrInvalidDestination, name, errors.Join(errs...))
+}
+
+// authenticationProperties lists the properties read by an authentication type of HTTP destinations.
+// Alternatives among the required properties are separated by "|".
+type authenticationProperties struct {
+	required []string
+	optional []string
+}
+
+var (
+	oauth2Required = []string{"clientId", "clientSecret|tokenService.KeyStoreLocation", "tokenServiceURL"}
+	oauth2Optional = []string{"tokenServiceURLType", "scope", "tokenService.KeyStorePassword", "tokenServiceUser", "tokenServicePassword"}
+	samlOptional   = []string{"nameIdFormat", "nameQualifier", "assertionRecipient", "userIdSource"}
+)
+
+// authenticationRequirements holds the required and optional properties of each authentication type
+var authenticationRequirements = map[string]authenticationProperties{
+	AppToAppSSOAuthentication:             {},
+	BasicAuthentication:                   {required: []string{UserProperty, PasswordProperty}},
+	ClientCertificateAuthentication:       {required: []string{KeyStoreLocationProperty}, optional: []string{KeyStorePasswordProperty}},
+	NoAuthentication:                      {},
+	OAuth2AuthorizationCodeAuthentication: {required: oauth2Required, optional: oauth2Optional},
+	OAuth2ClientCredentialsAuthentication: {required: oauth2Required, optional: oauth2Optional},
+	OAuth2JWTBearerAuthentication:         {required: oauth2Required, optional: oauth2Optional},
+	OAuth2PasswordAuthentication:          {required: append(slices.Clone(oauth2Required), UserProperty, PasswordProperty), optional: oauth2Optional},
+	OAuth2SAMLBearerAssertionAuthentication: {
+		required: []string{"audience", "authnContextClassRef", "clientKey", "tokenServiceURL"},
+		optional: append([]string{"tokenServiceURLType", "tokenServiceUser", "tokenServicePassword", "companyId", "SystemUser"}, samlOptional...),
+	},
+	OAuth2TechnicalUserPropagationAuthentication: {required: oauth2Required, optional: oauth2Optional},
+	OAuth2UserTokenExchangeAuthentication:        {required: oauth2Required, optional: oauth2Optional},
+	PrincipalPropagationAuthentication:           {},
+	SAMLAssertionAuthentication:                  {required: []string{"audience", "authnContextClassRef"}, optional: samlOptional},
+	SAPAssetionSSOAuthentication:                 {required: []string{"IssuerSID", "IssuerClient", "RecipientSID", "RecipientClient", "Certificate", "SigningKey"}},
+}
+
+// dependentProperties maps optional properties to the property that must be set along with them
+var dependentProperties = map[string]string{
+	KeyStorePasswordProperty:        KeyStoreLocationProperty,
+	"tokenService.KeyStorePassword": "tokenService.KeyStoreLocation",
+	"tokenServiceUser":              "tokenServicePassword",
+	"tokenServicePassword":          "tokenServiceUser",
+}
+
+// AuthenticationProperties returns the properties that an HTTP destination with the given authentication type must
+// set, and those it may set. Required alternatives are separated by "|", e.g. "clientSecret|tokenService.KeyStoreLocation".
+// Both are nil for unknown authentication types.
+func AuthenticationProperties(authentication string) (required []string, optional []string) {
+	properties := authenticationRequirements[authentication]
+	return slices.Clone(properties.required), slices.Clone(properties.optional)
+}
+
+// onPremiseAuthentications can only be used by destinations with the OnPremise proxy type
+var onPremiseAuthentications = map[string]bool{
+	PrincipalPropagationAuthentication:           true,
+	OAuth2TechnicalUserPropagationAuthentication: true,
+}
+
+// Validate checks a destination before it is created or updated, and returns all the problems found.
+// HTTP destinations are checked for the properties required by their authentication type, and for the properties
+// that the optional ones they set depend on, e.g. tokenServiceUser needs tokenServicePassword. MAIL, LDAP and RFC
+// destinations are checked as by the Validate methods of their typed configurations.
+func Validate(d Destination) []ValidationError {
+	var v validationErrors
+	if d.Name == "" {
+		v.add("Name", "Name is required")
+	}
+	switch d.Type {
+	case HTTPDestination:
+		validateHTTP(d.Properties, &v)
+	case MailDestination:
+		c, _ := NewMailDestinationConfig(d)
+		c.validate(&v)
+	case LDAPDestination:
+		c, _ := NewLDAPDestinationConfig(d)
+		c.validate(&v)
+	case RFCDestination:
+		c, _ := NewRFCDestinationConfig(d)
+		c.validate(&v)
+	case "":
+		v.add("Type", "Type is required")
+	default:
+		v.add("Type", "Type %q is not supported", d.Type)
+	}
+	return v
+}
+
+func validateHTTP(properties map[string]string, v *validationErrors) {
+	if destinationURL := properties[URLProperty]; destinationURL == "" {
+		v.require(URLProperty, destinationURL)
+	} else if u, err := url.Parse(destinationURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add(URLProperty, "%s %q must be an absolute http or https URL", URLProperty, destinationURL)
+	}
+
+	proxyType := properties[ProxyTypeProperty]
+	switch proxyType {
+	case InternetProxy, OnPremiseProxy:
+	case "":
+		v.require(ProxyTypeProperty, proxyType)
+	default:
+		v.add(ProxyTypeProperty, "%s %q is not supported", ProxyTypeProperty, proxyType)
+	}
+
+	authentication := properties[AuthenticationProperty]
+	requirements, ok := authenticationRequirements[authentication]
+	switch {
+	case authentication == "":
+		v.require(AuthenticationProperty, authentication)
+	case !ok:
+		v.add(AuthenticationProperty, "%s %q is not supported", AuthenticationProperty, authentication)
+	case onPremiseAuthentications[authentication] && proxyType != OnPremiseProxy:
+		v.add(ProxyTypeProperty, "%s requires %s %s", authentication, ProxyTypeProperty, OnPremiseProxy)
+	}
+	for _, names := range requirements.required {
+		alternatives := strings.Split(names, "|")
+		present := false
+		for _, name := range alternatives {
+			present = present || properties[name] != ""
+		}
+		if !present {
+			v.add(alternatives[0], "%s is required for %s", strings.Join(alternatives, " or "), authentication)
+		}
+	}
+	for _, name := range requirements.optional {
+		dependency, ok := dependentProperties[name]
+		if !ok || properties[name] == "" || properties[dependency] != "" {
+			continue
+		}
+		if !slices.ContainsFunc(*v, func(e ValidationError) bool { return e.Property == dependency }) {
+			v.add(dependency, "%s is required when %s is set", dependency, name)
+		}
+	}
+
+	if tokenServiceURL := properties["tokenServiceURL"]; tokenServiceURL != "" {
+		if u, err := url.Parse(tokenServiceURL); err != nil || u.Scheme == "" || u.Host == "" {
+			v.add("tokenServiceURL", "tokenServiceURL %q must be an absolute URL", tokenServiceURL)
+		}
+	}
+	switch urlType := properties["tokenServiceURLType"]; urlType {
+	case "", "Dedicated", "Common":
+	default:
+		v.add("tokenServiceURLType", "tokenServiceURLType %q must be Dedicated or Common", urlType)
+	}
+	if _, err := parseFlag(properties[TrustAllProperty]); err != nil {
+		v.add(TrustAllProperty, "%s %q is not a boolean", TrustAllProperty, properties[TrustAllProperty])
+	}
+	switch verifier := properties[HostnameVerifierProperty]; verifier {
+	case "", StrictHostnameVerifier, BrowserCompatibleHostnameVerifier:
+	default:
+		v.add(HostnameVerifierProperty, "%s %q is not supported", HostnameVerifierProperty, verifier)
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright (C) 2019 Lior Okman <lior.okman@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gosapcpdestinationclient
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestValidateHTTPAuthentication(t *testing.T) {
+	oauth2 := map[string]string{"clientId": "id", "clientSecret": "secret", "tokenServiceURL": "https://auth.example.com/oauth/token"}
+	with := func(base map[string]string, extra ...string) map[string]string {
+		properties := map[string]string{URLProperty: "https://backend.example.com", ProxyTypeProperty: InternetProxy}
+		for k, v := range base {
+			properties[k] = v
+		}
+		for i := 0; i+1 < len(extra); i += 2 {
+			properties[extra[i]] = extra[i+1]
+		}
+		return properties
+	}
+
+	tests := []struct {
+		authentication string
+		valid          map[string]string
+		// properties reported when only the URL and proxy type are set
+		missing []string
+	}{
+		{AppToAppSSOAuthentication, with(nil), nil},
+		{BasicAuthentication, with(nil, UserProperty, "alice", PasswordProperty, "secret"), []string{UserProperty, PasswordProperty}},
+		{ClientCertificateAuthentication, with(nil, KeyStoreLocationProperty, "client.p12"), []string{KeyStoreLocationProperty}},
+		{NoAuthentication, with(nil), nil},
+		{OAuth2AuthorizationCodeAuthentication, with(oauth2), []string{"clientId", "clientSecret", "tokenServiceURL"}},
+		{OAuth2ClientCredentialsAuthentication, with(oauth2), []string{"clientId", "clientSecret", "tokenServiceURL"}},
+		{OAuth2JWTBearerAuthentication, with(oauth2), []string{"clientId", "clientSecret", "tokenServiceURL"}},
+		{OAuth2PasswordAuthentication, with(oauth2, UserProperty, "alice", PasswordProperty, "secret"), []string{"clientId", "clientSecret", "tokenServiceURL", UserProperty, PasswordProperty}},
+		{OAuth2SAMLBearerAssertionAuthentication, with(nil, "audience", "a", "authnContextClassRef", "c", "clientKey", "k", "tokenServiceURL", "https://auth.example.com"), []string{"audience", "authnContextClassRef", "clientKey", "tokenServiceURL"}},
+		{OAuth2TechnicalUserPropagationAuthentication, with(oauth2, ProxyTypeProperty, OnPremiseProxy), []string{"clientId", "clientSecret", "tokenServiceURL"}},
+		{OAuth2UserTokenExchangeAuthentication, with(oauth2), []string{"clientId", "clientSecret", "tokenServiceURL"}},
+		{PrincipalPropagationAuthentication, with(nil, ProxyTypeProperty, OnPremiseProxy), nil},
+		{SAMLAssertionAuthentication, with(nil, "audience", "a", "authnContextClassRef", "c"), []string{"audience", "authnContextClassRef"}},
+		{SAPAssetionSSOAuthentication, with(nil, "IssuerSID", "S", "IssuerClient", "100", "RecipientSID", "R", "RecipientClient", "200", "Certificate", "c", "SigningKey", "k"),
+			[]string{"IssuerSID", "IssuerClient", "RecipientSID", "RecipientClient", "Certificate", "SigningKey"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.authentication, func(t *testing.T) {
+			valid := tt.valid
+			valid[AuthenticationProperty] = tt.authentication
+			if errs := Validate(Destination{Name: "dest", Type: HTTPDestination, Properties: valid}); len(errs) > 0 {
+				t.Errorf("unexpected errors %v", errs)
+			}
+
+			proxyType := valid[ProxyTypeProperty]
+			errs := Validate(Destination{Name: "dest", Type: HTTPDestination, Properties: with(nil,
+				AuthenticationProperty, tt.authentication, ProxyTypeProperty, proxyType)})
+			var missing []string
+			for _, e := range errs {
+				missing = append(missing, e.Property)
+			}
+			if !reflect.DeepEqual(missing, tt.missing) {
+				t.Errorf("expected missing %v, got %v", tt.missing, errs)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		dest Destination
+		want []string
+	}{
+		{"empty", Destination{}, []string{"Name", "Type"}},
+		{"unknown type", Destination{Name: "d", Type: "FTP"}, []string{"Type"}},
+		{"http without properties", Destination{Name: "d", Type: HTTPDestination}, []string{URLProperty, ProxyTypeProperty, AuthenticationProperty}},
+		{"unknown authentication", Destination{Name: "d", Type: HTTPDestination, Properties: map[string]string{
+			URLProperty: "https://h", ProxyTypeProperty: InternetProxy, AuthenticationProperty: "Kerberos",
+		}}, []string{AuthenticationProperty}},
+		{"relative URL", Destination{Name: "d", Type: HTTPDestination, Properties: map[string]string{
+			URLProperty: "/path", ProxyTypeProperty: InternetProxy, AuthenticationProperty: NoAuthentication,
+		}}, []string{URLProperty}},
+		{"principal propagation on the internet", Destination{Name: "d", Type: HTTPDestination, Properties: map[string]string{
+			URLProperty: "https://h", ProxyTypeProperty: InternetProxy, AuthenticationProperty: PrincipalPropagationAuthentication,
+		}}, []string{ProxyTypeProperty}},
+		{"client secret alternative", Destination{Name: "d", Type: HTTPDestination, Properties: map[string]string{
+			URLProperty: "https://h", ProxyTypeProperty: InternetProxy, AuthenticationProperty: OAuth2ClientCredentialsAuthentication,
+			"clientId": "id", "tokenService.KeyStoreLocation": "client.p12", "tokenServiceURL": "https://auth", "tokenServiceURLType": "Shared",
+			TrustAllProperty: "maybe", HostnameVerifierProperty: "None",
+		}}, []string{"tokenServiceURLType", TrustAllProperty, HostnameVerifierProperty}},
+		{"dependent properties", Destination{Name: "d", Type: HTTPDestination, Properties: map[string]string{
+			URLProperty: "https://h", ProxyTypeProperty: InternetProxy, AuthenticationProperty: OAuth2ClientCredentialsAuthentication,
+			"clientId": "id", "clientSecret": "secret", "tokenServiceURL": "https://auth",
+			"tokenService.KeyStorePassword": "changeit", "tokenServiceUser": "alice",
+		}}, []string{"tokenService.KeyStoreLocation", "tokenServicePassword"}},
+		{"client certificate password", Destination{Name: "d", Type: HTTPDestination, Properties: map[string]string{
+			URLProperty: "https://h", ProxyTypeProperty: InternetProxy, AuthenticationProperty: ClientCertificateAuthentication,
+			KeyStorePasswordProperty: "changeit",
+		}}, []string{KeyStoreLocationProperty}},
+		{"mail", Destination{Name: "d", Type: MailDestination}, []string{"mail.smtp.host"}},
+		{"ldap", Destination{Name: "d", Type: LDAPDestination}, []string{"ldap.url"}},
+		{"rfc", Destination{Name: "d", Type: RFCDestination, Properties: map[string]string{
+			"jco.client.ashost": "erp", "jco.client.sysnr": "00", "jco.client.client": "100", "jco.destination.auth_type": RFCPrincipalPropagation,
+		}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, e := range Validate(tt.dest) {
+				got = append(got, e.Property)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected problems with %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAuthenticationProperties(t *testing.T) {
+	required, optional := AuthenticationProperties(OAuth2PasswordAuthentication)
+	if !reflect.DeepEqual(required, []string{"clientId", "clientSecret|tokenService.KeyStoreLocation", "tokenServiceURL", UserProperty, PasswordProperty}) {
+		t.Errorf("unexpected required properties %v", required)
+	}
+	if !slices.Contains(optional, "scope") || !slices.Contains(optional, "tokenServiceUser") {
+		t.Errorf("unexpected optional properties %v", optional)
+	}
+	required[0] = "changed"
+	if again, _ := AuthenticationProperties(OAuth2PasswordAuthentication); again[0] != "clientId" {
+		t.Errorf("the returned properties are shared: %v", again)
+	}
+	if required, optional := AuthenticationProperties(ClientCertificateAuthentication); !reflect.DeepEqual(required, []string{KeyStoreLocationProperty}) ||
+		!reflect.DeepEqual(optional, []string{KeyStorePasswordProperty}) {
+		t.Errorf("unexpected client certificate properties %v %v", required, optional)
+	}
+	if required, optional := AuthenticationProperties("Kerberos"); required != nil || optional != nil {
+		t.Errorf("expected no properties for an unknown type, got %v %v", required, optional)
+	}
+}
+
+func TestWithValidation(t *testing.T) {
+	var requests int
+	client, _ := newTestClientWithConf(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusCreated)
+	}), nil, WithValidation())
+
+	invalid := Destination{Name: "backend", Type: HTTPDestination, Properties: map[string]string{
+		URLProperty: "https://backend.example.com", ProxyTypeProperty: InternetProxy, AuthenticationProperty: BasicAuthentication,
+	}}
+	err := client.CreateSubaccountDestination(invalid)
+	if !errors.Is(err, ErrInvalidDestination) {
+		t.Fatalf("expected ErrInvalidDestination, got %v", err)
+	}
+	var validationErr ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Property != UserProperty {
+		t.Errorf("expected a ValidationError for %s, got %v", UserProperty, err)
+	}
+	if _, err := client.UpdateInstanceDestination(invalid); !errors.Is(err, ErrInvalidDestination) {
+		t.Errorf("expected ErrInvalidDestination, got %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("expected invalid destinations not to be sent, got %d requests", requests)
+	}
+
+	invalid.Properties[UserProperty] = "alice"
+	invalid.Properties[PasswordProperty] = "secret"
+	if err := client.CreateInstanceDestination(invalid); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Errorf("expected the valid destination to be sent, got %d requests", requests)
+	}
+}